@@ -0,0 +1,246 @@
+package vcluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// repoIndex is a struct matching the parts of a helm repository's index.yaml used by the provider.
+type repoIndex struct {
+	Entries map[string][]chartEntry `json:"entries"`
+}
+
+// chartEntry is a struct matching a published version of a chart in a helm repository's index.yaml.
+type chartEntry struct {
+	Version     string `json:"version"`
+	KubeVersion string `json:"kubeVersion"`
+}
+
+// fetchChartVersions returns the versions of the chart published in the helm repository, as listed by its index.
+func fetchChartVersions(ctx context.Context, repo string, chart string) ([]string, error) {
+	entries, err := fetchChartEntries(ctx, repo, chart)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []string{}
+	for _, entry := range entries {
+		versions = append(versions, entry.Version)
+	}
+
+	return versions, nil
+}
+
+// fetchChartEntries returns the published versions of the chart in the helm repository, as listed by its index.
+func fetchChartEntries(ctx context.Context, repo string, chart string) ([]chartEntry, error) {
+	url := strings.TrimSuffix(repo, "/") + "/index.yaml"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: unexpected status %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var index repoIndex
+	if err := yaml.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", url, err)
+	}
+
+	return index.Entries[chart], nil
+}
+
+// distroCharts maps the distros to the name of their chart in the loft chart repo.
+var distroCharts = map[string]string{
+	"k3s": "vcluster",
+	"k0s": "vcluster-k0s",
+	"k8s": "vcluster-k8s",
+}
+
+// resolveDistro returns the first of the candidate distros supported by the host cluster. A distro is supported when its
+// chart, in the chart_version when that is set, is published in the chart repo of the vcluster and the kubernetes
+// versions the chart supports include the version of the host. When the chart is configured explicitly the first
+// candidate is used.
+func resolveDistro(ctx context.Context, d *schema.ResourceData, meta *Meta, candidates []string) (string, diag.Diagnostics) {
+	if d.Get("chart").(string) != "" || d.Get("local_chart_dir").(string) != "" {
+		return candidates[0], nil
+	}
+
+	hostVersion, diags := hostKubernetesVersion(ctx, d, meta)
+	if diags.HasError() {
+		return "", diags
+	}
+
+	repo := d.Get("chart_repo").(string)
+	if repo == "" {
+		repo = LoftChartRepo
+	}
+
+	chartVersion := strings.TrimPrefix(d.Get("chart_version").(string), "v")
+
+	for _, candidate := range candidates {
+		entries, err := fetchChartEntries(ctx, repo, distroCharts[strings.ToLower(candidate)])
+		if err != nil {
+			return "", diag.FromErr(err)
+		}
+
+		for _, entry := range entries {
+			if chartVersion != "" && strings.TrimPrefix(entry.Version, "v") != chartVersion {
+				continue
+			}
+
+			if supportsKubeVersion(ctx, entry, hostVersion) {
+				return candidate, nil
+			}
+
+			tflog.Info(ctx, "the distro does not support the kubernetes version of the host cluster", map[string]interface{}{
+				"distro":       candidate,
+				"chart":        entry.Version,
+				"kube_version": entry.KubeVersion,
+				"host_version": hostVersion.Original(),
+			})
+
+			// only the latest, or the configured, version of the chart is installed.
+			break
+		}
+	}
+
+	return "", diag.Diagnostics{
+		{
+			Severity: diag.Error,
+			Summary:  "none of the distro candidates are supported by the host cluster",
+			Detail:   fmt.Sprintf("candidates: %s, repo: %s, host kubernetes version: %s", strings.Join(candidates, ", "), repo, hostVersion.Original()),
+		},
+	}
+}
+
+// supportsKubeVersion returns true if the kubeVersion constraint of the chart, which helm enforces at install, includes
+// the kubernetes version of the host. A chart without a valid constraint supports every version.
+func supportsKubeVersion(ctx context.Context, entry chartEntry, hostVersion *semver.Version) bool {
+	if entry.KubeVersion == "" {
+		return true
+	}
+
+	constraint, err := semver.NewConstraint(entry.KubeVersion)
+	if err != nil {
+		tflog.Warn(ctx, "unable to parse the kubeVersion of the chart, assuming it is supported", map[string]interface{}{
+			"kube_version": entry.KubeVersion,
+			"error":        err.Error(),
+		})
+		return true
+	}
+
+	return constraint.Check(hostVersion)
+}
+
+// kubectlVersion is a struct matching the parts of kubectl version's json output used by the provider.
+type kubectlVersion struct {
+	ServerVersion struct {
+		GitVersion string `json:"gitVersion"`
+	} `json:"serverVersion"`
+}
+
+// hostKubernetesVersion returns the kubernetes version of the host cluster of the vcluster, as reported by its api
+// server.
+func hostKubernetesVersion(ctx context.Context, d *schema.ResourceData, meta *Meta) (*semver.Version, diag.Diagnostics) {
+	args := []string{"version", "--output", "json"}
+	if context := vclusterContext(d, meta); context != "" {
+		args = append(args, "--context", context)
+	}
+
+	output, diags := runKubectl(ctx, meta, args)
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	// kubectl warns about a version skew with the server after the json document, so only the document is decoded.
+	var parsed kubectlVersion
+	if err := json.NewDecoder(bytes.NewReader(jsonOutput(output))).Decode(&parsed); err != nil {
+		return nil, append(diags, diag.Diagnostic{Severity: diag.Error, Summary: "unable to parse the output of kubectl version", Detail: err.Error()})
+	}
+
+	hostVersion, err := semver.NewVersion(parsed.ServerVersion.GitVersion)
+	if err != nil {
+		return nil, append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "unable to parse the kubernetes version of the host cluster",
+			Detail:   fmt.Sprintf("%q: %s", parsed.ServerVersion.GitVersion, err),
+		})
+	}
+
+	return hostVersion, diags
+}
+
+// checkChartVersion verifies that the chart version of the vcluster is published in its chart repo, so that an invalid
+// version fails the plan instead of the create. Local charts and OCI repos are not checked, and the check is skipped
+// when the index cannot be fetched, e.g. for authenticated repos or air-gapped runners.
+func checkChartVersion(ctx context.Context, d *schema.ResourceDiff) error {
+	chartVersion := d.Get("chart_version").(string)
+	if chartVersion == "" || d.Get("local_chart_dir").(string) != "" {
+		return nil
+	}
+
+	// the chart of distro candidates is only known once they are resolved at create time.
+	if len(d.Get("distro_candidates").([]interface{})) > 0 {
+		return nil
+	}
+
+	chart := d.Get("chart").(string)
+	if chart == "" {
+		distro := strings.ToLower(d.Get("distro").(string))
+		if distro == "" {
+			distro = "k3s"
+		}
+		chart = distroCharts[distro]
+	}
+
+	repo := d.Get("chart_repo").(string)
+	if repo == "" {
+		repo = LoftChartRepo
+	}
+
+	if strings.HasPrefix(repo, "oci://") {
+		return nil
+	}
+
+	versions, err := fetchChartVersions(ctx, repo, chart)
+	if err != nil {
+		tflog.Warn(ctx, "unable to fetch the index of the chart repo, skipping the chart version check", map[string]interface{}{
+			"repo":  repo,
+			"error": err.Error(),
+		})
+		return nil
+	}
+
+	for _, version := range versions {
+		if strings.TrimPrefix(version, "v") == strings.TrimPrefix(chartVersion, "v") {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("chart %s version %s does not exist in %s, available versions: %s", chart, chartVersion, repo, strings.Join(versions, ", "))
+}
@@ -0,0 +1,115 @@
+package vcluster
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestCheckChartVersion(t *testing.T) {
+	repo := testChartRepo(t, testRepoIndex)
+
+	unauthorized := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	t.Cleanup(unauthorized.Close)
+
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	cases := []struct {
+		repo         string
+		chartVersion string
+		fails        bool
+	}{
+		{repo: repo.URL, chartVersion: "0.13.0"},
+		{repo: repo.URL, chartVersion: "v0.12.3"},
+		{repo: repo.URL, chartVersion: "0.99.0", fails: true},
+		// the check is skipped when the index cannot be fetched.
+		{repo: unauthorized.URL, chartVersion: "0.99.0"},
+		{repo: unreachable.URL, chartVersion: "0.99.0"},
+		{repo: "oci://registry.example.com/charts", chartVersion: "0.99.0"},
+	}
+
+	for _, c := range cases {
+		meta, _ := testMeta(t, map[string]interface{}{})
+
+		_, err := resourceVCluster().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(map[string]interface{}{
+			"name":          "test",
+			"chart_repo":    c.repo,
+			"chart_version": c.chartVersion,
+		}), meta)
+		if (err != nil) != c.fails {
+			t.Errorf("planning chart version %s from %s: unexpected error %v", c.chartVersion, c.repo, err)
+		}
+	}
+}
+
+// testDistroIndex is the index of a chart repo whose distro charts support different kubernetes versions.
+const testDistroIndex = `apiVersion: v1
+entries:
+  vcluster-k0s:
+  - name: vcluster-k0s
+    version: 0.13.0
+    kubeVersion: ">=1.25.0-0"
+  vcluster-k8s:
+  - name: vcluster-k8s
+    version: 0.13.0
+    kubeVersion: ">=1.22.0-0"
+  - name: vcluster-k8s
+    version: 0.12.3
+    kubeVersion: ">=1.20.0-0"
+  vcluster:
+  - name: vcluster
+    version: 0.13.0
+`
+
+// testKubectlVersion returns the output of kubectl version for a host cluster in the version, followed by the warning
+// kubectl prints about a version skew.
+func testKubectlVersion(gitVersion string) string {
+	return `{"clientVersion": {"gitVersion": "v1.26.0"}, "serverVersion": {"gitVersion": "` + gitVersion + `"}}
+WARNING: version difference between client (1.26) and server exceeds the supported minor version skew of +/-1
+`
+}
+
+func TestResolveDistro(t *testing.T) {
+	repo := testChartRepo(t, testDistroIndex)
+
+	cases := []struct {
+		hostVersion  string
+		candidates   []string
+		chartVersion string
+		expected     string
+	}{
+		{hostVersion: "v1.26.1", candidates: []string{"k0s", "k8s"}, expected: "k0s"},
+		{hostVersion: "v1.24.6-eks-4360b32", candidates: []string{"k0s", "k8s"}, expected: "k8s"},
+		{hostVersion: "v1.21.14+k3s1", candidates: []string{"k0s", "k8s", "k3s"}, expected: "k3s"},
+		{hostVersion: "v1.21.14+k3s1", candidates: []string{"k8s", "k3s"}, chartVersion: "0.12.3", expected: "k8s"},
+		{hostVersion: "v1.21.14", candidates: []string{"k0s", "k8s"}},
+	}
+
+	for _, c := range cases {
+		meta, runner := testMeta(t, map[string]interface{}{})
+		runner.on("kubectl version", fakeResult{stdout: testKubectlVersion(c.hostVersion)})
+
+		d := testVCluster(t, map[string]interface{}{
+			"name":          "test",
+			"context":       "kind-host",
+			"chart_repo":    repo.URL,
+			"chart_version": c.chartVersion,
+		})
+
+		distro, diags := resolveDistro(context.Background(), d, meta, c.candidates)
+		if diags.HasError() != (c.expected == "") {
+			t.Fatalf("host %s, candidates %q: unexpected diagnostics %v", c.hostVersion, c.candidates, diags)
+		}
+		if distro != c.expected {
+			t.Fatalf("host %s, candidates %q: expected %q, got %q", c.hostVersion, c.candidates, c.expected, distro)
+		}
+
+		runner.find(t, "kubectl version --output json --context kind-host")
+	}
+}
@@ -0,0 +1,361 @@
+package vcluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/mitchellh/go-homedir"
+)
+
+// newCommand builds a command with the process settings configured on the provider.
+func newCommand(ctx context.Context, meta *Meta, name string, args []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = os.Environ()
+	cmd.Dir = meta.workingDir
+
+	if meta.disableColor {
+		// Keep ANSI escape codes and spinners out of the captured output so that it can be parsed and reported.
+		cmd.Env = append(cmd.Env, "NO_COLOR=1", "TERM=dumb")
+	}
+
+	if meta.disableTelemetry {
+		cmd.Env = append(cmd.Env, "VCLUSTER_TELEMETRY_DISABLED=true")
+	}
+
+	// the variables are added in a stable order, later ones take precedence over the environment of the process.
+	keys := make([]string, 0, len(meta.env))
+	for key := range meta.env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		cmd.Env = append(cmd.Env, key+"="+meta.env[key])
+	}
+
+	return cmd
+}
+
+// runCommand executes the command, returning its output or a diagnostic describing the failed command.
+func runCommand(ctx context.Context, meta *Meta, name string, args []string) ([]byte, diag.Diagnostics) {
+	if meta.commands != nil {
+		select {
+		case meta.commands <- struct{}{}:
+			defer func() { <-meta.commands }()
+		case <-ctx.Done():
+			return nil, diag.FromErr(ctx.Err())
+		}
+	}
+
+	output, err := commandOutput(ctx, meta, name, args)
+	if exitErr, ok := err.(exitCoder); ok && acceptsExitCode(meta, name, args, exitErr.ExitCode()) {
+		return output, diag.Diagnostics{
+			{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("%s %s exited with the acceptable code %d", name, strings.Join(args, " "), exitErr.ExitCode()),
+				Detail:   string(output),
+			},
+		}
+	}
+
+	if err != nil {
+		return output, diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  fmt.Sprint(name, " ", strings.Join(args, " ")),
+				Detail:   string(output),
+			},
+		}
+	}
+
+	return output, nil
+}
+
+// acceptableExitCodeSubcommands are the subcommands of the vcluster cli whose acceptable exit codes are treated as
+// success. The failures of other commands, such as list and delete, decide what is recorded in the state, so they are
+// never treated as success.
+var acceptableExitCodeSubcommands = map[string]bool{
+	"create":  true,
+	"connect": true,
+}
+
+// acceptsExitCode returns true if the non-zero exit code of the command is treated as success.
+func acceptsExitCode(meta *Meta, name string, args []string, code int) bool {
+	if !meta.acceptableExitCodes[code] || name != vclusterBinary(meta) || len(args) == 0 {
+		return false
+	}
+
+	return acceptableExitCodeSubcommands[args[0]]
+}
+
+// runner runs a command whose output streams are already set up. It is exec.Cmd.Run unless replaced, which lets tests
+// stub the output of the clis.
+type runner func(cmd *exec.Cmd) error
+
+// exitCoder is implemented by the errors of commands that ran and exited with a non-zero code.
+type exitCoder interface {
+	ExitCode() int
+}
+
+// run runs the command with the runner of the provider.
+func (m *Meta) run(cmd *exec.Cmd) error {
+	if m.runner != nil {
+		return m.runner(cmd)
+	}
+
+	return cmd.Run()
+}
+
+// commandOutput runs the command, returning its combined output. When stderr is forwarded, the output of a successful
+// command is only its stdout and every line of its stderr is logged as a warning instead, so that deprecation notices
+// are visible without affecting the parsing of the output.
+func commandOutput(ctx context.Context, meta *Meta, name string, args []string) ([]byte, error) {
+	cmd := newCommand(ctx, meta, name, args)
+	if !meta.forwardStderr {
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+
+		err := meta.run(cmd)
+		return output.Bytes(), err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := meta.run(cmd); err != nil {
+		return append(stdout.Bytes(), stderr.Bytes()...), err
+	}
+
+	for _, line := range strings.Split(stderr.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			tflog.Warn(ctx, line, map[string]interface{}{
+				"command": name,
+			})
+		}
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// transientErrors are outputs of failed commands caused by an overloaded api server, which are retried.
+var transientErrors = []string{
+	"context deadline exceeded",
+}
+
+// isTransient returns true if the output of a failed command indicates an error worth retrying.
+func isTransient(output []byte) bool {
+	for _, transient := range transientErrors {
+		if bytes.Contains(output, []byte(transient)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// insertFlag adds the flag to the arguments of the vcluster cli, in front of the arguments after -- which the cli passes
+// on to another command.
+func insertFlag(args []string, flag string) []string {
+	for i, arg := range args {
+		if arg == "--" {
+			return append(append(append([]string{}, args[:i]...), flag), args[i:]...)
+		}
+	}
+
+	return append(args, flag)
+}
+
+// runVCluster executes the vcluster cli, retrying with an exponential backoff while it fails with transient errors
+// until the context is done. When json logs are enabled, the diagnostics of a failed command are built from its
+// structured log output.
+func runVCluster(ctx context.Context, meta *Meta, args []string) ([]byte, diag.Diagnostics) {
+	jsonLogs := meta.jsonLogs && supportsJSONLogs(ctx, meta)
+	if jsonLogs {
+		args = insertFlag(args, "--log-output=json")
+	}
+
+	backoff := time.Second
+	for {
+		output, diags := runCommand(ctx, meta, vclusterBinary(meta), args)
+		if !diags.HasError() {
+			return output, diags
+		}
+
+		if !isTransient(output) {
+			if jsonLogs {
+				if parsed := jsonLogDiagnostics(diags[0].Summary, output); parsed.HasError() {
+					return output, parsed
+				}
+			}
+
+			return output, diags
+		}
+
+		tflog.Warn(ctx, "the vcluster cli failed with a transient error, retrying", map[string]interface{}{
+			"args":    strings.Join(args, " "),
+			"backoff": backoff.String(),
+		})
+
+		select {
+		case <-ctx.Done():
+			return output, diags
+		case <-time.After(backoff):
+		}
+
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+
+		args = retryArgs(args)
+	}
+}
+
+// retryArgs returns the arguments a failed command is retried with. A create may have installed the vcluster before
+// failing, so it is retried as an upgrade, which also installs the vcluster when it does not exist.
+func retryArgs(args []string) []string {
+	if len(args) == 0 || args[0] != "create" {
+		return args
+	}
+
+	for _, arg := range args {
+		if arg == "--upgrade" {
+			return args
+		}
+	}
+
+	return append(args[:len(args):len(args)], "--upgrade")
+}
+
+// runHelm executes the helm cli.
+func runHelm(ctx context.Context, meta *Meta, args []string) ([]byte, diag.Diagnostics) {
+	return runCommand(ctx, meta, "helm", args)
+}
+
+// runKubectl executes the kubectl cli.
+func runKubectl(ctx context.Context, meta *Meta, args []string) ([]byte, diag.Diagnostics) {
+	return runCommand(ctx, meta, "kubectl", args)
+}
+
+// sensitiveFlagRegexp matches the names of flags whose values are redacted from recorded commands.
+var sensitiveFlagRegexp = regexp.MustCompile(`(?i)^--?[a-z0-9-]*(token|password|secret|key)[a-z0-9-]*$`)
+
+// sensitiveValueKeyRegexp matches the keys of the helm values whose values are redacted from the set flags of recorded
+// commands.
+var sensitiveValueKeyRegexp = regexp.MustCompile(`(?i)(token|password|secret|key)[^.]*$`)
+
+// setFlags are the flags setting a helm value with a key=value pair.
+var setFlags = map[string]bool{
+	"--set":         true,
+	"--set-string":  true,
+	"--set-literal": true,
+}
+
+// redactSetValue returns the key=value pair of a set flag, with the value replaced when the key is sensitive.
+func redactSetValue(value string) string {
+	if key, _, ok := strings.Cut(value, "="); ok && sensitiveValueKeyRegexp.MatchString(key) {
+		return key + "=" + redacted
+	}
+	return value
+}
+
+// redactCommand returns the command line with the values of sensitive flags replaced, so that it can be recorded.
+func redactCommand(name string, args []string) string {
+	redactedArgs := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		flag, value, hasValue := strings.Cut(args[i], "=")
+		switch {
+		case setFlags[flag] && hasValue:
+			redactedArgs = append(redactedArgs, flag+"="+redactSetValue(value))
+		case setFlags[flag] && i+1 < len(args):
+			redactedArgs = append(redactedArgs, flag, redactSetValue(args[i+1]))
+			i++
+		case !sensitiveFlagRegexp.MatchString(flag):
+			redactedArgs = append(redactedArgs, args[i])
+		case hasValue:
+			redactedArgs = append(redactedArgs, flag+"="+redacted)
+		case i+1 < len(args) && !strings.HasPrefix(args[i+1], "-"):
+			redactedArgs = append(redactedArgs, flag, redacted)
+			i++
+		default:
+			redactedArgs = append(redactedArgs, flag)
+		}
+	}
+
+	return strings.Join(append([]string{name}, redactedArgs...), " ")
+}
+
+// resolvePath expands a leading ~ to the home directory of the user, and resolves a relative path against the working
+// directory of the provider.
+func resolvePath(meta *Meta, path string) string {
+	if expanded, err := homedir.Expand(path); err == nil {
+		path = expanded
+	}
+
+	if meta.workingDir == "" || filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(meta.workingDir, path)
+}
+
+// vclusterBinary returns the resolved path of the vcluster cli, a bare name is looked up in the PATH when run.
+func vclusterBinary(meta *Meta) string {
+	return executablePath(meta, meta.binaryPath)
+}
+
+// executablePath resolves the path of an executable like resolvePath, except that a bare name is kept as is so that it
+// is looked up in the PATH when run.
+func executablePath(meta *Meta, path string) string {
+	if !strings.ContainsRune(path, filepath.Separator) && !strings.HasPrefix(path, "~") {
+		return path
+	}
+
+	return resolvePath(meta, path)
+}
+
+// validateExecutable validates that the value is the path of an executable, or the name of one found in the PATH.
+func validateExecutable(val interface{}, key cty.Path) diag.Diagnostics {
+	path, err := homedir.Expand(val.(string))
+	if err == nil {
+		_, err = exec.LookPath(path)
+	}
+
+	if err != nil {
+		return diag.Diagnostics{{
+			Severity:      diag.Error,
+			Summary:       fmt.Sprintf("%q is not an executable", val.(string)),
+			Detail:        err.Error(),
+			AttributePath: key,
+		}}
+	}
+
+	return nil
+}
+
+// jsonOutput strips any lines the cli printed before the json document in its output, such as warnings or json log
+// entries.
+func jsonOutput(output []byte) []byte {
+	offset := 0
+	for _, line := range bytes.SplitAfter(output, []byte("\n")) {
+		trimmed := bytes.TrimSpace(line)
+		if _, isLog := parseLogEntry(trimmed); !isLog && (bytes.HasPrefix(trimmed, []byte("[")) || bytes.HasPrefix(trimmed, []byte("{"))) {
+			return output[offset:]
+		}
+
+		offset += len(line)
+	}
+
+	return output
+}
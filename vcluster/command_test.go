@@ -0,0 +1,375 @@
+package vcluster
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/mitchellh/go-homedir"
+)
+
+// hasEnv returns true if the environment contains the variable.
+func hasEnv(env []string, variable string) bool {
+	for _, v := range env {
+		if v == variable {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRunCommandDisableColor(t *testing.T) {
+	for _, disableColor := range []bool{true, false} {
+		meta, runner := testMeta(t, map[string]interface{}{"disable_color": disableColor})
+
+		if _, diags := runCommand(context.Background(), meta, "vcluster", []string{"list"}); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		env := runner.find(t, "vcluster list").Env
+		for _, variable := range []string{"NO_COLOR=1", "TERM=dumb"} {
+			if hasEnv(env, variable) != disableColor {
+				t.Fatalf("disable_color = %t: expected %s in the environment to be %t", disableColor, variable, disableColor)
+			}
+		}
+	}
+}
+
+func TestRunCommandDisableTelemetry(t *testing.T) {
+	for _, disableTelemetry := range []bool{true, false} {
+		meta, runner := testMeta(t, map[string]interface{}{"disable_telemetry": disableTelemetry})
+
+		if _, diags := runCommand(context.Background(), meta, "vcluster", []string{"list"}); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		if hasEnv(runner.find(t, "vcluster list").Env, "VCLUSTER_TELEMETRY_DISABLED=true") != disableTelemetry {
+			t.Fatalf("disable_telemetry = %t: expected VCLUSTER_TELEMETRY_DISABLED in the environment to be %t", disableTelemetry, disableTelemetry)
+		}
+	}
+}
+
+func TestRunCommandAcceptableExitCodes(t *testing.T) {
+	cases := []struct {
+		name    string
+		args    []string
+		code    int
+		success bool
+	}{
+		{name: "vcluster", args: []string{"create", "test", "--upgrade"}, code: 3, success: true},
+		{name: "vcluster", args: []string{"connect", "test", "--update-current=true"}, code: 3, success: true},
+		{name: "vcluster", args: []string{"create", "test"}, code: 1},
+		{name: "vcluster", args: []string{"list", "--output", "json"}, code: 3},
+		{name: "vcluster", args: []string{"delete", "test"}, code: 3},
+		{name: "helm", args: []string{"create", "chart"}, code: 3},
+		{name: "kubectl", args: []string{"get", "pods"}, code: 3},
+		{name: "docker", args: []string{"rm", "--force", "vcluster_test"}, code: 3},
+	}
+
+	for _, c := range cases {
+		meta, runner := testMeta(t, map[string]interface{}{"acceptable_exit_codes": []interface{}{3}})
+		runner.on(c.name, fakeResult{stdout: "nothing to do\n", err: exitError(c.code)})
+
+		output, diags := runCommand(context.Background(), meta, c.name, c.args)
+		if diags.HasError() == c.success {
+			t.Fatalf("%s %q exiting with %d: expected success to be %t, got %v", c.name, c.args, c.code, c.success, diags)
+		}
+		if c.success && (len(diags) != 1 || diags[0].Severity != diag.Warning || string(output) != "nothing to do\n") {
+			t.Fatalf("%s %q exiting with %d: expected the output as a warning, got %v", c.name, c.args, c.code, diags)
+		}
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	cases := []struct {
+		output   string
+		expected string
+	}{
+		{
+			output:   `[{"Name": "test"}]`,
+			expected: `[{"Name": "test"}]`,
+		},
+		{
+			output:   "warn   Your vcluster cli is outdated, please upgrade\n[{\"Name\": \"test\"}]\n",
+			expected: "[{\"Name\": \"test\"}]\n",
+		},
+		{
+			output:   "{\"level\": \"warn\", \"msg\": \"deprecated flag\"}\n[\n  {\"Name\": \"test\"}\n]\n",
+			expected: "[\n  {\"Name\": \"test\"}\n]\n",
+		},
+	}
+
+	for _, c := range cases {
+		if actual := string(jsonOutput([]byte(c.output))); actual != c.expected {
+			t.Errorf("jsonOutput(%q): expected %q, got %q", c.output, c.expected, actual)
+		}
+	}
+}
+
+func TestResolvePathWorkingDir(t *testing.T) {
+	cases := []struct {
+		workingDir string
+		path       string
+		expected   string
+	}{
+		{workingDir: "", path: "charts/vcluster", expected: "charts/vcluster"},
+		{workingDir: "/work", path: "charts/vcluster", expected: "/work/charts/vcluster"},
+		{workingDir: "/work", path: "../charts", expected: "/charts"},
+		{workingDir: "/work", path: "/opt/charts", expected: "/opt/charts"},
+	}
+
+	for _, c := range cases {
+		meta, _ := testMeta(t, map[string]interface{}{"working_dir": c.workingDir})
+		if actual := resolvePath(meta, c.path); actual != c.expected {
+			t.Errorf("resolving %q against %q: expected %q, got %q", c.path, c.workingDir, c.expected, actual)
+		}
+	}
+}
+
+func TestResolvePathHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	homedir.Reset()
+	t.Cleanup(homedir.Reset)
+
+	cases := []struct {
+		workingDir string
+		path       string
+		expected   string
+	}{
+		{workingDir: "", path: "~", expected: home},
+		{workingDir: "", path: "~/charts/vcluster", expected: filepath.Join(home, "charts/vcluster")},
+		{workingDir: "/work", path: "~", expected: home},
+		{workingDir: "/work", path: "~/charts/vcluster", expected: filepath.Join(home, "charts/vcluster")},
+		{workingDir: "/work", path: "/opt/charts", expected: "/opt/charts"},
+	}
+
+	for _, c := range cases {
+		meta, _ := testMeta(t, map[string]interface{}{"working_dir": c.workingDir})
+		if actual := resolvePath(meta, c.path); actual != c.expected {
+			t.Errorf("resolving %q against %q: expected %q, got %q", c.path, c.workingDir, c.expected, actual)
+		}
+	}
+}
+
+func TestRunCommandWorkingDir(t *testing.T) {
+	meta, runner := testMeta(t, map[string]interface{}{"working_dir": "/work"})
+
+	if _, diags := runCommand(context.Background(), meta, "vcluster", []string{"list"}); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	if dir := runner.find(t, "vcluster list").Dir; dir != "/work" {
+		t.Fatalf("expected the command to run in /work, got %q", dir)
+	}
+}
+
+func TestRunCommandParallelism(t *testing.T) {
+	meta, _ := testMeta(t, map[string]interface{}{"parallelism": 2})
+
+	var mu sync.Mutex
+	running, peak := 0, 0
+	meta.runner = func(cmd *exec.Cmd) error {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runCommand(context.Background(), meta, "vcluster", []string{"list"})
+		}()
+	}
+	wg.Wait()
+
+	if peak != 2 {
+		t.Fatalf("expected at most 2 commands to run concurrently, %d did", peak)
+	}
+}
+
+func TestCommandOutputForwardStderr(t *testing.T) {
+	for _, forward := range []bool{true, false} {
+		meta, runner := testMeta(t, map[string]interface{}{"forward_stderr": forward})
+		runner.on("vcluster list", fakeResult{
+			stdout: `[{"Name": "test"}]`,
+			stderr: "warn   Flag --output-format has been deprecated\n\nwarn   Your vcluster cli is outdated\n",
+		})
+
+		var logs bytes.Buffer
+		ctx := tflogtest.RootLogger(context.Background(), &logs)
+
+		output, err := commandOutput(ctx, meta, "vcluster", []string{"list"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		entries, err := tflogtest.MultilineJSONDecode(&logs)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		warnings := []string{}
+		for _, entry := range entries {
+			if entry["@level"] == "warn" && entry["command"] == "vcluster" {
+				warnings = append(warnings, entry["@message"].(string))
+			}
+		}
+
+		if !forward {
+			if len(warnings) != 0 || !strings.Contains(string(output), "deprecated") {
+				t.Fatalf("expected stderr to stay in the output, got the output %q and warnings %q", output, warnings)
+			}
+			continue
+		}
+
+		if string(output) != `[{"Name": "test"}]` {
+			t.Fatalf("expected the output to be stdout, got %q", output)
+		}
+
+		expected := []string{"warn   Flag --output-format has been deprecated", "warn   Your vcluster cli is outdated"}
+		if !reflect.DeepEqual(warnings, expected) {
+			t.Fatalf("expected the warnings %q, got %q", expected, warnings)
+		}
+	}
+}
+
+func TestRunVClusterRetriesCreateAsUpgrade(t *testing.T) {
+	meta, _ := testMeta(t, map[string]interface{}{})
+
+	ran := [][]string{}
+	meta.runner = func(cmd *exec.Cmd) error {
+		ran = append(ran, cmd.Args[1:])
+		if len(ran) == 1 {
+			// the release was installed, but the cli timed out waiting for the api server.
+			io.WriteString(cmd.Stdout, "fatal   context deadline exceeded\n")
+			return exitError(1)
+		}
+		return nil
+	}
+
+	if _, diags := runVCluster(context.Background(), meta, []string{"create", "test", "--connect=false"}); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	expected := [][]string{
+		{"create", "test", "--connect=false"},
+		{"create", "test", "--connect=false", "--upgrade"},
+	}
+	if !reflect.DeepEqual(ran, expected) {
+		t.Fatalf("expected %q, ran %q", expected, ran)
+	}
+}
+
+func TestRetryArgs(t *testing.T) {
+	cases := []struct {
+		args     []string
+		expected []string
+	}{
+		{args: []string{"create", "test"}, expected: []string{"create", "test", "--upgrade"}},
+		{args: []string{"create", "test", "--upgrade"}, expected: []string{"create", "test", "--upgrade"}},
+		{args: []string{"list", "--output", "json"}, expected: []string{"list", "--output", "json"}},
+	}
+
+	for _, c := range cases {
+		if actual := retryArgs(c.args); !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("retrying %q: expected %q, got %q", c.args, c.expected, actual)
+		}
+	}
+}
+
+func TestRedactCommand(t *testing.T) {
+	cases := []struct {
+		args     []string
+		expected string
+	}{
+		{
+			args:     []string{"connect", "test", "--token", "secret-token", "--namespace", "team"},
+			expected: "vcluster connect test --token (redacted) --namespace team",
+		},
+		{
+			args:     []string{"connect", "test", "--token=secret-token", "--access-key=secret-key"},
+			expected: "vcluster connect test --token=(redacted) --access-key=(redacted)",
+		},
+		{
+			// a sensitive flag without a value is kept as is.
+			args:     []string{"login", "--use-access-key", "--insecure"},
+			expected: "vcluster login --use-access-key --insecure",
+		},
+		{
+			args: []string{
+				"create", "test",
+				"--set-literal", "syncer.extraEnv.apiToken=secret-token",
+				"--set-literal", "vcluster.image=rancher/k3s:v1.25.5-k3s1",
+				"--set=auth.password=secret",
+				"--set-string", "registry.credentials.accessKey=secret-key",
+			},
+			expected: "vcluster create test" +
+				" --set-literal syncer.extraEnv.apiToken=(redacted)" +
+				" --set-literal vcluster.image=rancher/k3s:v1.25.5-k3s1" +
+				" --set=auth.password=(redacted)" +
+				" --set-string registry.credentials.accessKey=(redacted)",
+		},
+		{
+			// only the last segment of the key is sensitive.
+			args:     []string{"create", "test", "--set", "secrets.enabled=true"},
+			expected: "vcluster create test --set secrets.enabled=true",
+		},
+	}
+
+	for _, c := range cases {
+		if actual := redactCommand("vcluster", c.args); actual != c.expected {
+			t.Errorf("redacting %q: expected %q, got %q", c.args, c.expected, actual)
+		}
+	}
+}
+
+func TestRunVClusterJSONLogsFlag(t *testing.T) {
+	cases := []struct {
+		args     []string
+		expected string
+	}{
+		{
+			args:     []string{"list", "--output", "json"},
+			expected: "vcluster list --output json --log-output=json",
+		},
+		{
+			args:     []string{"connect", "test", "--", "kubectl", "get", "nodes"},
+			expected: "vcluster connect test --log-output=json -- kubectl get nodes",
+		},
+	}
+
+	for _, c := range cases {
+		meta, runner := testMeta(t, map[string]interface{}{"json_logs": true})
+		runner.on("vcluster --version", fakeResult{stdout: "vcluster version 0.13.0\n"})
+
+		if _, diags := runVCluster(context.Background(), meta, c.args); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		if line := strings.Join(runner.find(t, "vcluster "+c.args[0]).Args, " "); line != c.expected {
+			t.Fatalf("expected %q, ran %q", c.expected, line)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package vcluster
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// connectionDetails are the details of the current context of a kubeconfig, in the form expected by the kubernetes
+// and helm providers.
+type connectionDetails struct {
+	Context              string
+	Host                 string
+	Endpoint             string
+	Token                string
+	ClusterCACertificate string
+	ClientCertificate    string
+	ClientKey            string
+}
+
+// parseConnectionDetails extracts the connection details of the current context of the kubeconfig. Both token and
+// client certificate based kubeconfigs are supported.
+func parseConnectionDetails(kubeconfig []byte) (connectionDetails, error) {
+	config, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return connectionDetails{}, err
+	}
+
+	current, ok := config.Contexts[config.CurrentContext]
+	if !ok {
+		return connectionDetails{}, fmt.Errorf("the kubeconfig has no context named %q", config.CurrentContext)
+	}
+
+	details := connectionDetails{Context: config.CurrentContext}
+
+	if cluster, ok := config.Clusters[current.Cluster]; ok {
+		server, err := normalizeServer(cluster.Server)
+		if err != nil {
+			return connectionDetails{}, err
+		}
+		details.Host = server.String()
+		details.Endpoint = server.Host
+		details.ClusterCACertificate = string(cluster.CertificateAuthorityData)
+	}
+
+	if authInfo, ok := config.AuthInfos[current.AuthInfo]; ok {
+		details.Token = authInfo.Token
+		details.ClientCertificate = string(authInfo.ClientCertificateData)
+		details.ClientKey = string(authInfo.ClientKeyData)
+	}
+
+	return details, nil
+}
+
+// normalizeServer parses the server of a kubeconfig, with an explicit scheme and port as kubeconfigs may omit either.
+func normalizeServer(server string) (*url.URL, error) {
+	if !strings.Contains(server, "://") {
+		server = "https://" + server
+	}
+
+	parsed, err := url.Parse(strings.TrimSuffix(server, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("parsing the kubeconfig server %q: %w", server, err)
+	}
+
+	if parsed.Port() == "" {
+		port := "443"
+		if parsed.Scheme == "http" {
+			port = "80"
+		}
+		parsed.Host = net.JoinHostPort(parsed.Hostname(), port)
+	}
+
+	return parsed, nil
+}
+
+// updateKubeConfig adds a context for the vcluster to the kube config of the user running terraform when update_current
+// is set. The cli only does so when it connects, which creating the vcluster skips, so it connects separately. Unless the
+// vcluster is exposed, it is reached through a background proxy.
+func updateKubeConfig(ctx context.Context, d *schema.ResourceData, meta *Meta) diag.Diagnostics {
+	if !d.Get("update_current").(bool) {
+		return nil
+	}
+
+	args := vclusterBaseArgs(d, meta, []string{
+		"connect",
+		vclusterName(d),
+		"--update-current=true",
+	})
+
+	if contextName := d.Get("kube_context_name"); contextName != nil && contextName.(string) != "" {
+		args = append(args, fmt.Sprintf("--kube-config-context-name=%s", contextName.(string)))
+	}
+
+	if !d.Get("expose").(bool) {
+		args = append(args, "--background-proxy=true")
+	}
+
+	_, diags := runVCluster(ctx, meta, args)
+	return diags
+}
+
+// readConnectionDetails records the kubeconfig of the vcluster and the connection details parsed from it. Failures are
+// reported as warnings, as the vcluster may not be reachable yet.
+func readConnectionDetails(ctx context.Context, d *schema.ResourceData, meta *Meta) diag.Diagnostics {
+	args := vclusterBaseArgs(d, meta, []string{
+		"connect",
+		vclusterName(d),
+		"--print",
+		"--update-current=false",
+	})
+
+	if contextName := d.Get("kube_context_name"); contextName != nil && contextName.(string) != "" {
+		args = append(args, fmt.Sprintf("--kube-config-context-name=%s", contextName.(string)))
+	}
+
+	ttl := d.Get("kubeconfig_token_ttl").(string)
+	if ttl != "" {
+		seconds := 0
+		if duration, err := time.ParseDuration(ttl); err == nil {
+			seconds = int(duration.Seconds())
+		}
+
+		args = append(args,
+			fmt.Sprintf("--service-account=%s", d.Get("kubeconfig_service_account").(string)),
+			"--cluster-role=cluster-admin",
+			fmt.Sprintf("--token-expiration=%d", seconds),
+		)
+	}
+
+	if address := d.Get("advertise_address"); address != nil && address.(string) != "" {
+		host := address.(string)
+		if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+			host = "[" + host + "]"
+		}
+
+		args = append(args, fmt.Sprintf("--server=https://%s", host))
+	}
+
+	output, diags := runVCluster(ctx, meta, args)
+	if diags.HasError() {
+		for i := range diags {
+			diags[i].Severity = diag.Warning
+		}
+		d.Set("cert_expiry", "")
+		return diags
+	}
+
+	details, err := parseConnectionDetails(output)
+	if err != nil {
+		d.Set("cert_expiry", "")
+		return diag.Diagnostics{{Severity: diag.Warning, Summary: "unable to parse the vcluster kubeconfig", Detail: err.Error()}}
+	}
+
+	// the api server may cap the lifetime of the tokens it issues, in which case a non-expiring token cannot be had.
+	if ttl == "0" && tokenExpires(details.Token) {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "the kubeconfig token expires",
+			Detail:   "A token that does not expire was requested, but the api server of the vcluster issued one that expires.",
+		})
+	}
+
+	d.Set("kubeconfig", string(output))
+	d.Set("kubeconfig_context", details.Context)
+	d.Set("host", details.Host)
+	d.Set("endpoint", details.Endpoint)
+	d.Set("token", details.Token)
+	d.Set("cluster_ca_certificate", details.ClusterCACertificate)
+	d.Set("client_certificate", details.ClientCertificate)
+	d.Set("client_key", details.ClientKey)
+
+	expiry, err := readCertExpiry(ctx, d, meta, details)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{Severity: diag.Warning, Summary: "unable to read the expiry of the vcluster certificate", Detail: err.Error()})
+		d.Set("cert_expiry", "")
+	} else {
+		d.Set("cert_expiry", expiry.UTC().Format(time.RFC3339))
+	}
+
+	return diags
+}
+
+// readCertExpiry returns when the certificate of the vcluster expires. The served certificate is only read when
+// served_cert_expiry is set, as it dials the api server on every refresh, otherwise the expiry of the certificate
+// authority is returned.
+func readCertExpiry(ctx context.Context, d *schema.ResourceData, meta *Meta, details connectionDetails) (time.Time, error) {
+	if d.Get("served_cert_expiry").(bool) {
+		expiry, err := servedCertExpiry(ctx, meta, details.Host)
+		if err == nil {
+			return expiry, nil
+		}
+
+		// the served certificate cannot be read through a background proxy that is not running, or a port that is not
+		// exposed, so the expiry of the certificate authority is reported instead.
+		tflog.Debug(ctx, "unable to read the certificate served by the vcluster, falling back to its certificate authority", map[string]interface{}{
+			"host":  details.Host,
+			"error": err.Error(),
+		})
+	}
+
+	return certExpiry(details.ClusterCACertificate)
+}
+
+// servedCertTimeout bounds reading the certificate served by the api server of a vcluster.
+const servedCertTimeout = 5 * time.Second
+
+// servedCertExpiry returns when the certificate served by the api server at the https host expires. The certificate is
+// not verified, as only its expiry is read.
+func servedCertExpiry(ctx context.Context, meta *Meta, host string) (time.Time, error) {
+	server, err := url.Parse(host)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if server.Scheme != "https" {
+		return time.Time{}, fmt.Errorf("%s is not served over https", host)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, servedCertTimeout)
+	defer cancel()
+
+	dial := (&net.Dialer{}).DialContext
+	if meta.dial != nil {
+		dial = meta.dial
+	}
+
+	rawConn, err := dial(ctx, "tcp", server.Host)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	conn := tls.Client(rawConn, &tls.Config{InsecureSkipVerify: true})
+	defer conn.Close()
+
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return time.Time{}, err
+	}
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return time.Time{}, fmt.Errorf("%s served no certificate", host)
+	}
+
+	return certs[0].NotAfter, nil
+}
+
+// certExpiry returns when the first certificate of the PEM encoded bundle expires.
+func certExpiry(bundle string) (time.Time, error) {
+	block, _ := pem.Decode([]byte(bundle))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return time.Time{}, fmt.Errorf("the kubeconfig has no PEM encoded certificate authority")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return cert.NotAfter, nil
+}
+
+// tokenExpiry returns when the token expires, false if it is not a jwt with an expiry.
+func tokenExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var claims struct {
+		Expiry int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Expiry == 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(claims.Expiry, 0), true
+}
+
+// tokenExpires returns true if the token is a jwt with an expiry.
+func tokenExpires(token string) bool {
+	_, expires := tokenExpiry(token)
+	return expires
+}
+
+// keepsIssuedToken returns true if the state holds a token issued for kubeconfig_token_ttl that has not expired yet.
+// Every connect with kubeconfig_token_ttl issues a new token, so refreshes keep the issued one instead of replacing it.
+func keepsIssuedToken(d *schema.ResourceData) bool {
+	token := d.Get("token").(string)
+	if d.IsNewResource() || d.Get("kubeconfig_token_ttl").(string) == "" || token == "" {
+		return false
+	}
+
+	expiry, expires := tokenExpiry(token)
+	return !expires || time.Now().Before(expiry)
+}
@@ -0,0 +1,356 @@
+package vcluster
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// testConnectKubeConfig returns a kubeconfig like the one printed by vcluster connect, for the context and server,
+// authenticating with the user block.
+func testConnectKubeConfig(contextName, server, ca, user string) string {
+	return fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: %[1]s
+  cluster:
+    server: %[2]s
+    certificate-authority-data: %[3]s
+contexts:
+- name: %[1]s
+  context:
+    cluster: %[1]s
+    user: %[1]s
+current-context: %[1]s
+users:
+- name: %[1]s
+  user:
+%[4]s
+`, contextName, server, base64.StdEncoding.EncodeToString([]byte(ca)), user)
+}
+
+func TestParseConnectionDetails(t *testing.T) {
+	encode := func(data string) string {
+		return base64.StdEncoding.EncodeToString([]byte(data))
+	}
+
+	cases := []struct {
+		name       string
+		kubeconfig string
+		expected   connectionDetails
+	}{
+		{
+			name:       "token",
+			kubeconfig: testConnectKubeConfig("vcluster_test_team_kind-host", "https://vcluster.example.com", "ca", "    token: secret-token"),
+			expected: connectionDetails{
+				Context:              "vcluster_test_team_kind-host",
+				Host:                 "https://vcluster.example.com:443",
+				Endpoint:             "vcluster.example.com:443",
+				Token:                "secret-token",
+				ClusterCACertificate: "ca",
+			},
+		},
+		{
+			name: "client certificate",
+			kubeconfig: testConnectKubeConfig("my-vcluster", "localhost:8443", "ca",
+				"    client-certificate-data: "+encode("cert")+"\n    client-key-data: "+encode("key")),
+			expected: connectionDetails{
+				Context:              "my-vcluster",
+				Host:                 "https://localhost:8443",
+				Endpoint:             "localhost:8443",
+				ClusterCACertificate: "ca",
+				ClientCertificate:    "cert",
+				ClientKey:            "key",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		details, err := parseConnectionDetails([]byte(c.kubeconfig))
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if details != c.expected {
+			t.Errorf("%s: expected %+v, got %+v", c.name, c.expected, details)
+		}
+	}
+
+	if _, err := parseConnectionDetails([]byte("apiVersion: v1\nkind: Config\ncurrent-context: missing\n")); err == nil {
+		t.Fatal("expected a kubeconfig without its current context to fail")
+	}
+}
+
+func TestParseConnectionDetailsHost(t *testing.T) {
+	cases := []struct {
+		server   string
+		host     string
+		endpoint string
+	}{
+		// a server with a scheme and port is the host as is.
+		{server: "https://10.0.0.1:8443", host: "https://10.0.0.1:8443", endpoint: "10.0.0.1:8443"},
+		{server: "https://[fd00::10]:443", host: "https://[fd00::10]:443", endpoint: "[fd00::10]:443"},
+		{server: "http://localhost:8080", host: "http://localhost:8080", endpoint: "localhost:8080"},
+		// the port and scheme default like they do for kubectl.
+		{server: "https://vcluster.example.com/", host: "https://vcluster.example.com:443", endpoint: "vcluster.example.com:443"},
+		{server: "http://localhost", host: "http://localhost:80", endpoint: "localhost:80"},
+		{server: "localhost:8443", host: "https://localhost:8443", endpoint: "localhost:8443"},
+	}
+
+	for _, c := range cases {
+		details, err := parseConnectionDetails([]byte(testConnectKubeConfig("vcluster_test", c.server, "ca", "    token: secret-token")))
+		if err != nil {
+			t.Fatalf("%s: %v", c.server, err)
+		}
+
+		if details.Host != c.host || details.Endpoint != c.endpoint {
+			t.Errorf("%s: expected the host %q and endpoint %q, got %q and %q", c.server, c.host, c.endpoint, details.Host, details.Endpoint)
+		}
+	}
+}
+
+func TestReadConnectionDetailsContext(t *testing.T) {
+	cases := []struct {
+		config   map[string]interface{}
+		context  string
+		expected string
+	}{
+		{
+			config:   map[string]interface{}{"name": "test", "kube_context_name": "dev"},
+			context:  "dev",
+			expected: "vcluster connect test --print --update-current=false --kube-config-context-name=dev",
+		},
+		{
+			config:   map[string]interface{}{"name": "test", "namespace": "team"},
+			context:  "vcluster_test_team_kind-host",
+			expected: "vcluster connect test --print --update-current=false --namespace team",
+		},
+	}
+
+	for _, c := range cases {
+		meta, runner := testMeta(t, map[string]interface{}{})
+		kubeconfig := testConnectKubeConfig(c.context, "https://localhost:8443", "ca", "    token: secret-token")
+		runner.on("vcluster connect", fakeResult{stdout: kubeconfig})
+
+		d := testVCluster(t, c.config)
+		if diags := readConnectionDetails(context.Background(), d, meta); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		runner.find(t, c.expected)
+
+		if actual := d.Get("kubeconfig_context").(string); actual != c.context {
+			t.Fatalf("expected the kubeconfig context %q, got %q", c.context, actual)
+		}
+
+		config, err := clientcmd.Load([]byte(d.Get("kubeconfig").(string)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := config.Contexts[d.Get("kubeconfig_context").(string)]; !ok || config.CurrentContext != c.context {
+			t.Fatalf("expected the kubeconfig context %q to be the current context of the kubeconfig", c.context)
+		}
+	}
+}
+
+func TestReadConnectionDetailsAdvertiseAddress(t *testing.T) {
+	cases := []struct {
+		address  string
+		expected string
+	}{
+		{address: "vcluster.example.com", expected: "--server=https://vcluster.example.com"},
+		{address: "10.0.0.10", expected: "--server=https://10.0.0.10"},
+		{address: "fd00::10", expected: "--server=https://[fd00::10]"},
+	}
+
+	for _, c := range cases {
+		meta, runner := testMeta(t, map[string]interface{}{})
+		server := strings.TrimPrefix(c.expected, "--server=")
+		runner.on("vcluster connect", fakeResult{
+			stdout: testConnectKubeConfig("vcluster_test", server, "ca", "    token: secret-token"),
+		})
+
+		d := testVCluster(t, map[string]interface{}{"name": "test", "advertise_address": c.address})
+		if diags := readConnectionDetails(context.Background(), d, meta); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		if args := runner.find(t, "vcluster connect test --print").Args; !hasArg(args, c.expected) {
+			t.Fatalf("expected %s in %q", c.expected, args)
+		}
+		if host := d.Get("host").(string); host != server+":443" {
+			t.Fatalf("expected the host to be the advertised address, got %q", host)
+		}
+	}
+}
+
+// testToken returns a jwt expiring at the time, a zero time omits the expiry.
+func testToken(expiry time.Time) string {
+	claims := `{"sub":"system:serviceaccount:kube-system:terraform"}`
+	if !expiry.IsZero() {
+		claims = fmt.Sprintf(`{"sub":"system:serviceaccount:kube-system:terraform","exp":%d}`, expiry.Unix())
+	}
+
+	encode := base64.RawURLEncoding.EncodeToString
+	return encode([]byte(`{"alg":"RS256"}`)) + "." + encode([]byte(claims)) + ".signature"
+}
+
+func TestResourceVClusterKubeConfigTokenTTL(t *testing.T) {
+	issued := testToken(time.Now().Add(24 * time.Hour))
+
+	// the token is issued when the vcluster is created.
+	meta, runner := testMeta(t, map[string]interface{}{})
+	runner.on("vcluster list", fakeResult{
+		stdout: `[{"Name": "test", "Status": "Running", "Created": "2022-12-09T03:12:10Z"}]`,
+	})
+	runner.on("vcluster connect test --print", fakeResult{
+		stdout: testConnectKubeConfig("vcluster_test", "https://localhost:8443", "ca", "    token: "+issued),
+	})
+	runner.on("kubectl get pods", fakeResult{stdout: `{"items": []}`})
+
+	d := testVCluster(t, map[string]interface{}{"name": "test", "kubeconfig_token_ttl": "24h"})
+	if diags := resourceVClusterCreate(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	args := runner.find(t, "vcluster connect test --print").Args
+	for _, arg := range []string{"--service-account=kube-system/terraform", "--cluster-role=cluster-admin", "--token-expiration=86400"} {
+		if !hasArg(args, arg) {
+			t.Fatalf("expected %s in %q", arg, args)
+		}
+	}
+	if token := d.Get("token").(string); token != issued {
+		t.Fatalf("expected the issued token, got %q", token)
+	}
+
+	cases := []struct {
+		token  string
+		issues bool
+	}{
+		{token: issued},
+		{token: testToken(time.Time{})},
+		{token: testToken(time.Now().Add(-time.Hour)), issues: true},
+		{token: "", issues: true},
+	}
+
+	for _, c := range cases {
+		meta, runner := testMeta(t, map[string]interface{}{})
+		runner.on("vcluster list", fakeResult{
+			stdout: `[{"Name": "test", "Status": "Running", "Created": "2022-12-09T03:12:10Z"}]`,
+		})
+		runner.on("kubectl get pods", fakeResult{stdout: `{"items": []}`})
+
+		state := testVCluster(t, map[string]interface{}{"name": "test", "kubeconfig_token_ttl": "24h"})
+		state.SetId("test")
+		state.Set("token", c.token)
+		d := resourceVCluster().Data(state.State())
+
+		if diags := resourceVClusterRead(context.Background(), d, meta); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		if issues := runner.ran("vcluster connect"); issues != c.issues {
+			t.Errorf("token %q: expected a token to be issued to be %t, ran %q", c.token, c.issues, runner.lines())
+		}
+		if !c.issues && d.Get("token").(string) != c.token {
+			t.Errorf("token %q: expected the token to be kept, got %q", c.token, d.Get("token").(string))
+		}
+	}
+
+	// the token is issued again when the ttl changes.
+	meta, runner = testMeta(t, map[string]interface{}{})
+	d = testVCluster(t, map[string]interface{}{"name": "test", "kubeconfig_token_ttl": "1h"})
+	d.SetId("test")
+	if diags := resourceVClusterUpdate(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if args := runner.find(t, "vcluster connect test --print").Args; !hasArg(args, "--token-expiration=3600") {
+		t.Fatalf("expected --token-expiration=3600 in %q", args)
+	}
+}
+
+func TestReadConnectionDetailsCertExpiry(t *testing.T) {
+	caNotAfter := time.Date(2032, 12, 9, 3, 12, 10, 0, time.UTC)
+	servedNotAfter := time.Date(2023, 12, 9, 3, 12, 10, 0, time.UTC)
+	ca, _ := testCertificate(t, caNotAfter)
+
+	served, servedKey := testCertificate(t, servedNotAfter)
+	pair, err := tls.X509KeyPair([]byte(served), []byte(servedKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{pair}}
+	server.StartTLS()
+	defer server.Close()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	cases := []struct {
+		server   string
+		served   bool
+		dials    bool
+		expected time.Time
+	}{
+		// the served certificate is only read when it is opted into.
+		{server: server.URL, expected: caNotAfter},
+		{server: server.URL, served: true, dials: true, expected: servedNotAfter},
+		// the certificate authority is the fallback when the served certificate cannot be read.
+		{server: "https://" + closed.Listener.Addr().String(), served: true, dials: true, expected: caNotAfter},
+		// a server without https is not dialed.
+		{server: closed.URL, served: true, expected: caNotAfter},
+	}
+
+	for _, c := range cases {
+		meta, runner := testMeta(t, map[string]interface{}{})
+		runner.on("vcluster connect", fakeResult{
+			stdout: testConnectKubeConfig("vcluster_test", c.server, ca, "    token: secret-token"),
+		})
+
+		dial := meta.dial
+		dialed := false
+		meta.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialed = true
+			return dial(ctx, network, address)
+		}
+
+		d := testVCluster(t, map[string]interface{}{"name": "test", "served_cert_expiry": c.served})
+		if diags := readConnectionDetails(context.Background(), d, meta); diags.HasError() || len(diags) != 0 {
+			t.Fatalf("%s: unexpected diagnostics: %v", c.server, diags)
+		}
+
+		if expiry := d.Get("cert_expiry").(string); expiry != c.expected.Format(time.RFC3339) {
+			t.Errorf("%s: expected the certificate to expire at %s, got %q", c.server, c.expected.Format(time.RFC3339), expiry)
+		}
+		if dialed != c.dials {
+			t.Errorf("%s: expected the api server to be dialed to be %t", c.server, c.dials)
+		}
+	}
+
+	// the expiry is cleared when the kubeconfig cannot be parsed.
+	meta, runner := testMeta(t, map[string]interface{}{})
+	runner.on("vcluster connect", fakeResult{stdout: "not a kubeconfig"})
+
+	d := testVCluster(t, map[string]interface{}{"name": "test"})
+	d.Set("cert_expiry", caNotAfter.Format(time.RFC3339))
+	if diags := readConnectionDetails(context.Background(), d, meta); diags.HasError() || len(diags) == 0 {
+		t.Fatalf("expected the unparsable kubeconfig to be a warning, got %v", diags)
+	}
+	if expiry := d.Get("cert_expiry").(string); expiry != "" {
+		t.Fatalf("expected the expiry to be cleared, got %q", expiry)
+	}
+
+	if _, err := certExpiry("not a certificate"); err == nil {
+		t.Fatal("expected a bundle without a certificate to fail")
+	}
+}
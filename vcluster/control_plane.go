@@ -0,0 +1,98 @@
+package vcluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// podList is a struct matching the parts of kubectl's json pod listing used by the provider.
+type podList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Spec struct {
+			NodeName string `json:"nodeName"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+// scheduledControlPlanePod returns the name and namespace of the first control plane pod scheduled to a node in the
+// listing, or empty strings if none is scheduled yet.
+func scheduledControlPlanePod(output []byte) (string, string, error) {
+	var pods podList
+	if err := json.Unmarshal(jsonOutput(output), &pods); err != nil {
+		return "", "", err
+	}
+
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName != "" {
+			return pod.Metadata.Name, pod.Metadata.Namespace, nil
+		}
+	}
+
+	return "", "", nil
+}
+
+// readControlPlanePod records the control plane pod of the vcluster for debugging.
+func readControlPlanePod(ctx context.Context, d *schema.ResourceData, meta *Meta, namespace string) diag.Diagnostics {
+	args := []string{
+		"get", "pods",
+		"--namespace", namespace,
+		"--selector", fmt.Sprintf("app=vcluster,release=%s", vclusterReleaseName(d)),
+		"--output", "json",
+	}
+
+	if context := vclusterContext(d, meta); context != "" {
+		args = append(args, "--context", context)
+	}
+
+	output, diags := runKubectl(ctx, meta, args)
+	if diags.HasError() {
+		for i := range diags {
+			diags[i].Severity = diag.Warning
+		}
+		return diags
+	}
+
+	name, podNamespace, err := scheduledControlPlanePod(output)
+	if err != nil {
+		return diag.Diagnostics{{Severity: diag.Warning, Summary: "unable to read the control plane pod", Detail: err.Error()}}
+	}
+
+	d.Set("control_plane_pod", name)
+	d.Set("control_plane_namespace", podNamespace)
+	return nil
+}
+
+// controlPlaneWorkload returns the kind and name of the workload running the control plane, which depends on the distro.
+func controlPlaneWorkload(d *schema.ResourceData) string {
+	kind := "statefulset"
+	if strings.EqualFold(vclusterDistro(d), "k8s") {
+		kind = "deployment"
+	}
+
+	return fmt.Sprintf("%s/%s", kind, vclusterReleaseName(d))
+}
+
+// restartControlPlane triggers a rollout restart of the control plane workload.
+func restartControlPlane(ctx context.Context, d *schema.ResourceData, meta *Meta) diag.Diagnostics {
+	args := []string{
+		"rollout", "restart",
+		controlPlaneWorkload(d),
+		"--namespace", vclusterNamespace(d),
+	}
+
+	if context := vclusterContext(d, meta); context != "" {
+		args = append(args, "--context", context)
+	}
+
+	_, diags := runKubectl(ctx, meta, args)
+	return diags
+}
@@ -0,0 +1,63 @@
+package vcluster
+
+import (
+	"context"
+	"testing"
+)
+
+// testPodListing is a kubectl json listing of the control plane pods of a vcluster, the first of which is not scheduled.
+const testPodListing = `{
+  "apiVersion": "v1",
+  "kind": "List",
+  "items": [
+    {
+      "metadata": {"name": "test-1", "namespace": "vcluster-test"},
+      "spec": {}
+    },
+    {
+      "metadata": {"name": "test-0", "namespace": "vcluster-test"},
+      "spec": {"nodeName": "worker-2"}
+    }
+  ]
+}`
+
+func TestScheduledControlPlanePod(t *testing.T) {
+	cases := []struct {
+		output    string
+		name      string
+		namespace string
+		fails     bool
+	}{
+		{output: testPodListing, name: "test-0", namespace: "vcluster-test"},
+		{output: `{"apiVersion": "v1", "kind": "List", "items": []}`},
+		{output: "error: the server doesn't have a resource type", fails: true},
+	}
+
+	for _, c := range cases {
+		name, namespace, err := scheduledControlPlanePod([]byte(c.output))
+		if (err != nil) != c.fails {
+			t.Fatalf("expected failure %t, got %v", c.fails, err)
+		}
+
+		if name != c.name || namespace != c.namespace {
+			t.Fatalf("expected %s/%s, got %s/%s", c.namespace, c.name, namespace, name)
+		}
+	}
+}
+
+func TestReadControlPlanePodUnparsable(t *testing.T) {
+	meta, runner := testMeta(t, map[string]interface{}{})
+	runner.on("kubectl get pods", fakeResult{stdout: "error: the server doesn't have a resource type"})
+
+	d := testVCluster(t, map[string]interface{}{"name": "test"})
+	d.Set("control_plane_pod", "test-0")
+
+	// the pod is only recorded for debugging, so failing to read it does not fail the read.
+	diags := readControlPlanePod(context.Background(), d, meta, "vcluster-test")
+	if len(diags) == 0 || diags.HasError() {
+		t.Fatalf("expected the unparsable pod listing to be a warning, got %v", diags)
+	}
+	if name := d.Get("control_plane_pod").(string); name != "test-0" {
+		t.Fatalf("expected the recorded pod to be kept, got %q", name)
+	}
+}
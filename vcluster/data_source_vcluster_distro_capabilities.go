@@ -0,0 +1,75 @@
+package vcluster
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// distroCapabilities describes the features supported by a distro.
+type distroCapabilities struct {
+	Datastores       []string
+	HighAvailability bool
+}
+
+// distroCapabilityTable maps the distros to their capabilities.
+var distroCapabilityTable = map[string]distroCapabilities{
+	"k3s": {
+		Datastores:       []string{"sqlite", "embedded-etcd", "external"},
+		HighAvailability: true,
+	},
+	"k0s": {
+		Datastores:       []string{"sqlite", "embedded-etcd"},
+		HighAvailability: false,
+	},
+	"k8s": {
+		Datastores:       []string{"etcd"},
+		HighAvailability: true,
+	},
+}
+
+func dataSourceVClusterDistroCapabilities() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceVClusterDistroCapabilitiesRead,
+
+		Schema: map[string]*schema.Schema{
+			"distro": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringInSlice(distroKinds, true),
+				Description:  "The distro to list the capabilities of",
+			},
+			"chart": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The name of the chart of the distro in the loft chart repo",
+			},
+			"datastores": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "The datastores the control plane of the distro can use",
+			},
+			"high_availability": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "True if the control plane of the distro can run with multiple replicas",
+			},
+		},
+	}
+}
+
+func dataSourceVClusterDistroCapabilitiesRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	distro := strings.ToLower(d.Get("distro").(string))
+	capabilities := distroCapabilityTable[distro]
+
+	d.SetId(distro)
+	d.Set("chart", distroCharts[distro])
+	d.Set("datastores", capabilities.Datastores)
+	d.Set("high_availability", capabilities.HighAvailability)
+
+	return nil
+}
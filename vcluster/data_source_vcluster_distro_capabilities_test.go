@@ -0,0 +1,45 @@
+package vcluster
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataSourceVClusterDistroCapabilitiesRead(t *testing.T) {
+	cases := []struct {
+		distro           string
+		chart            string
+		datastores       []interface{}
+		highAvailability bool
+	}{
+		{distro: "k3s", chart: "vcluster", datastores: []interface{}{"sqlite", "embedded-etcd", "external"}, highAvailability: true},
+		{distro: "k0s", chart: "vcluster-k0s", datastores: []interface{}{"sqlite", "embedded-etcd"}},
+		{distro: "k8s", chart: "vcluster-k8s", datastores: []interface{}{"etcd"}, highAvailability: true},
+		{distro: "K3S", chart: "vcluster", datastores: []interface{}{"sqlite", "embedded-etcd", "external"}, highAvailability: true},
+	}
+
+	for _, c := range cases {
+		d := schema.TestResourceDataRaw(t, dataSourceVClusterDistroCapabilities().Schema, map[string]interface{}{"distro": c.distro})
+		if diags := dataSourceVClusterDistroCapabilitiesRead(context.Background(), d, nil); diags.HasError() {
+			t.Fatalf("%s: unexpected diagnostics: %v", c.distro, diags)
+		}
+
+		if chart := d.Get("chart").(string); chart != c.chart {
+			t.Errorf("%s: expected the chart %q, got %q", c.distro, c.chart, chart)
+		}
+		if datastores := d.Get("datastores").([]interface{}); !reflect.DeepEqual(datastores, c.datastores) {
+			t.Errorf("%s: expected the datastores %q, got %q", c.distro, c.datastores, datastores)
+		}
+		if highAvailability := d.Get("high_availability").(bool); highAvailability != c.highAvailability {
+			t.Errorf("%s: expected high_availability to be %t, got %t", c.distro, c.highAvailability, highAvailability)
+		}
+	}
+
+	validate := dataSourceVClusterDistroCapabilities().Schema["distro"].ValidateFunc
+	if _, errs := validate("vanilla", "distro"); len(errs) == 0 {
+		t.Fatal("expected an unknown distro to be rejected")
+	}
+}
@@ -0,0 +1,95 @@
+package vcluster
+
+import (
+	"context"
+	"os/exec"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// redacted replaces the values of sensitive settings reported by the provider config data source.
+const redacted = "(redacted)"
+
+func dataSourceVClusterProviderConfig() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceVClusterProviderConfigRead,
+
+		Schema: map[string]*schema.Schema{
+			"binary_path": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The resolved path of the vcluster cli, empty if it cannot be found",
+			},
+			"context": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The kubernetes config context used by resources that do not set one",
+			},
+			"working_dir": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The working directory of the commands run by the provider",
+			},
+			"parallelism": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The maximum number of concurrently running commands, 0 means unlimited",
+			},
+			"disable_color": {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			"disable_telemetry": {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			"json_logs": {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			"kubernetes_host": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The host of the kubernetes block",
+			},
+			"kubernetes_token": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "(redacted) when the kubernetes block has a token, empty otherwise",
+			},
+		},
+	}
+}
+
+func dataSourceVClusterProviderConfigRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	provider := m.(*Meta)
+
+	binaryPath, err := exec.LookPath(vclusterBinary(provider))
+	if err != nil {
+		binaryPath = ""
+	}
+
+	d.SetId("provider")
+	d.Set("binary_path", binaryPath)
+	d.Set("context", provider.defaultContext)
+	d.Set("working_dir", provider.workingDir)
+	d.Set("parallelism", cap(provider.commands))
+	d.Set("disable_color", provider.disableColor)
+	d.Set("disable_telemetry", provider.disableTelemetry)
+	d.Set("json_logs", provider.jsonLogs)
+
+	if host, ok := k8sGetOk(provider.data, "host"); ok {
+		d.Set("kubernetes_host", host.(string))
+	} else {
+		d.Set("kubernetes_host", "")
+	}
+
+	if _, ok := k8sGetOk(provider.data, "token"); ok {
+		d.Set("kubernetes_token", redacted)
+	} else {
+		d.Set("kubernetes_token", "")
+	}
+
+	return nil
+}
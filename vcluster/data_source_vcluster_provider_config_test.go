@@ -0,0 +1,79 @@
+package vcluster
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataSourceVClusterProviderConfigRead(t *testing.T) {
+	testPath(t)
+
+	meta, _ := testMeta(t, map[string]interface{}{})
+	d := schema.TestResourceDataRaw(t, dataSourceVClusterProviderConfig().Schema, map[string]interface{}{})
+
+	if diags := dataSourceVClusterProviderConfigRead(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	defaults := map[string]interface{}{
+		"binary_path":       "",
+		"context":           "",
+		"working_dir":       "",
+		"parallelism":       0,
+		"disable_color":     true,
+		"disable_telemetry": false,
+		"json_logs":         false,
+		"kubernetes_host":   "",
+		"kubernetes_token":  "",
+	}
+	for key, expected := range defaults {
+		if actual := d.Get(key); actual != expected {
+			t.Errorf("%s: expected the default %#v, got %#v", key, expected, actual)
+		}
+	}
+}
+
+func TestDataSourceVClusterProviderConfigReadRedacted(t *testing.T) {
+	testPath(t, "vcluster")
+
+	meta, _ := testMeta(t, map[string]interface{}{
+		"parallelism": 4,
+		"json_logs":   true,
+		"kubernetes": []interface{}{map[string]interface{}{
+			"host":                   "https://host.example.com",
+			"token":                  "secret-token",
+			"cluster_ca_certificate": "ca",
+			"config_context":         "kind-host",
+		}},
+	})
+	d := schema.TestResourceDataRaw(t, dataSourceVClusterProviderConfig().Schema, map[string]interface{}{})
+
+	if diags := dataSourceVClusterProviderConfigRead(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	expected := map[string]interface{}{
+		"binary_path":      filepath.Join(os.Getenv("PATH"), "vcluster"),
+		"context":          "kind-host",
+		"parallelism":      4,
+		"json_logs":        true,
+		"kubernetes_host":  "https://host.example.com",
+		"kubernetes_token": redacted,
+	}
+	for key, value := range expected {
+		if actual := d.Get(key); actual != value {
+			t.Errorf("%s: expected %#v, got %#v", key, value, actual)
+		}
+	}
+
+	for key, value := range d.State().Attributes {
+		if strings.Contains(value, "secret-token") {
+			t.Fatalf("expected the token to be redacted, %s is %q", key, value)
+		}
+	}
+}
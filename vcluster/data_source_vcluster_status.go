@@ -0,0 +1,81 @@
+package vcluster
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func dataSourceVClusterStatus() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceVClusterStatusRead,
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The name of the vcluster",
+			},
+			"namespace": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The kubernetes namespace to use",
+			},
+			"context": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The kubernetes config context to use. Takes precedence over the config_context of the provider",
+			},
+			"found": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "True if the vcluster exists",
+			},
+			"status": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The status of the vcluster as reported by vcluster list",
+			},
+			"ready": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "True if the vcluster is running",
+			},
+			"paused": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "True if the vcluster is paused",
+			},
+			"created": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The time the vcluster was created at",
+			},
+		},
+	}
+}
+
+func dataSourceVClusterStatusRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	name := d.Get("name").(string)
+
+	entry, found, diags := findVCluster(ctx, d, m.(*Meta), name)
+	if diags.HasError() {
+		return diags
+	}
+
+	d.SetId(name)
+	d.Set("found", found)
+	d.Set("status", entry.Status)
+	d.Set("ready", entry.Status == "Running")
+	d.Set("paused", entry.Status == "Paused")
+
+	if found {
+		d.Set("created", entry.Created.Format(time.RFC3339))
+	} else {
+		d.Set("created", "")
+	}
+
+	return nil
+}
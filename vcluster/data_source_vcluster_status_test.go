@@ -0,0 +1,46 @@
+package vcluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataSourceVClusterStatusRead(t *testing.T) {
+	listed := `[
+  {"Name": "running", "Namespace": "vcluster-running", "Status": "Running", "Created": "2022-12-09T03:12:10Z"},
+  {"Name": "paused", "Namespace": "vcluster-paused", "Status": "Paused", "Created": "2022-12-10T08:00:00Z"}
+]`
+
+	cases := []struct {
+		name    string
+		found   bool
+		ready   bool
+		paused  bool
+		created string
+	}{
+		{name: "running", found: true, ready: true, created: "2022-12-09T03:12:10Z"},
+		{name: "paused", found: true, paused: true, created: "2022-12-10T08:00:00Z"},
+		{name: "absent"},
+	}
+
+	for _, c := range cases {
+		meta, runner := testMeta(t, map[string]interface{}{})
+		runner.on("vcluster list --output json", fakeResult{stdout: listed})
+
+		d := schema.TestResourceDataRaw(t, dataSourceVClusterStatus().Schema, map[string]interface{}{"name": c.name})
+		if diags := dataSourceVClusterStatusRead(context.Background(), d, meta); diags.HasError() {
+			t.Fatalf("%s: unexpected diagnostics %v", c.name, diags)
+		}
+
+		if d.Get("found").(bool) != c.found || d.Get("ready").(bool) != c.ready || d.Get("paused").(bool) != c.paused {
+			t.Fatalf("%s: expected found %t, ready %t and paused %t, got %t, %t and %t", c.name, c.found, c.ready, c.paused,
+				d.Get("found"), d.Get("ready"), d.Get("paused"))
+		}
+
+		if created := d.Get("created").(string); created != c.created {
+			t.Fatalf("%s: expected created %q, got %q", c.name, c.created, created)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package vcluster
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func dataSourceVClusterValuesValidate() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceVClusterValuesValidateRead,
+
+		Schema: map[string]*schema.Schema{
+			"extra_values": {
+				Type:        schema.TypeList,
+				Required:    true,
+				Description: "List of values in raw yaml format to validate against the chart.",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"distro": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "k3s",
+				ValidateFunc: validation.StringInSlice(distroKinds, true),
+				Description:  "The distro whose chart the values are rendered with, unless chart is set",
+			},
+			"chart": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The virtual cluster chart name to use",
+			},
+			"chart_version": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The virtual cluster chart version to use (e.g. v0.9.1)",
+			},
+			"chart_repo": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     LoftChartRepo,
+				Description: "The virtual cluster chart repo to use",
+			},
+			"valid": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "True if the chart renders with the values",
+			},
+			"error": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The error rendering the chart with the values, empty when they are valid",
+			},
+		},
+	}
+}
+
+func dataSourceVClusterValuesValidateRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	extraValues := expandStringSlice(d.Get("extra_values").([]interface{}))
+
+	values, err := mergeExtraValues(map[string]interface{}{}, extraValues)
+	if err != nil {
+		d.SetId(valuesID(extraValues))
+		d.Set("valid", false)
+		d.Set("error", err.Error())
+		return nil
+	}
+
+	valuesFile, err := writeValuesFile(values)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	defer os.Remove(valuesFile)
+
+	chart := d.Get("chart").(string)
+	if chart == "" {
+		chart = distroCharts[strings.ToLower(d.Get("distro").(string))]
+	}
+
+	args := []string{
+		"template", "vcluster", chart,
+		"--repo", d.Get("chart_repo").(string),
+		"--values", valuesFile,
+	}
+
+	if chartVersion := d.Get("chart_version").(string); chartVersion != "" {
+		args = append(args, "--version", chartVersion)
+	}
+
+	output, diags := runHelm(ctx, m.(*Meta), args)
+
+	d.SetId(valuesID(extraValues))
+	d.Set("valid", !diags.HasError())
+	if diags.HasError() {
+		d.Set("error", strings.TrimSpace(string(output)))
+	} else {
+		d.Set("error", "")
+	}
+
+	return nil
+}
+
+// valuesID returns an id for the data source derived from the values.
+func valuesID(extraValues []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(extraValues, "\n---\n")))
+	return hex.EncodeToString(sum[:])
+}
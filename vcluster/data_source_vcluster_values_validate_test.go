@@ -0,0 +1,76 @@
+package vcluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataSourceVClusterValuesValidateRead(t *testing.T) {
+	cases := []struct {
+		values []interface{}
+		result fakeResult
+		valid  bool
+		error  string
+	}{
+		{
+			values: []interface{}{"syncer:\n  replicas: 2\n"},
+			result: fakeResult{stdout: "---\nkind: StatefulSet\n"},
+			valid:  true,
+		},
+		{
+			values: []interface{}{"syncer:\n  replicas: two\n"},
+			result: fakeResult{
+				stderr: "Error: values don't meet the specifications of the schema(s)\n",
+				err:    exitError(1),
+			},
+			error: "Error: values don't meet the specifications of the schema(s)",
+		},
+		{
+			// values that are not yaml fail before the chart is rendered.
+			values: []interface{}{"syncer: [\n"},
+		},
+	}
+
+	for _, c := range cases {
+		meta, runner := testMeta(t, map[string]interface{}{})
+		runner.on("helm template vcluster vcluster-k8s", c.result)
+
+		d := schema.TestResourceDataRaw(t, dataSourceVClusterValuesValidate().Schema, map[string]interface{}{
+			"extra_values":  c.values,
+			"distro":        "k8s",
+			"chart_version": "0.13.0",
+		})
+
+		if diags := dataSourceVClusterValuesValidateRead(context.Background(), d, meta); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		if valid := d.Get("valid").(bool); valid != c.valid {
+			t.Fatalf("validating %q: expected valid to be %t", c.values, c.valid)
+		}
+		if c.error != "" && d.Get("error").(string) != c.error {
+			t.Fatalf("validating %q: expected the error %q, got %q", c.values, c.error, d.Get("error"))
+		}
+		if c.valid && d.Get("error").(string) != "" {
+			t.Fatalf("validating %q: expected no error, got %q", c.values, d.Get("error"))
+		}
+	}
+
+	meta, runner := testMeta(t, map[string]interface{}{})
+	d := schema.TestResourceDataRaw(t, dataSourceVClusterValuesValidate().Schema, map[string]interface{}{
+		"extra_values":  []interface{}{"syncer:\n  replicas: 2\n"},
+		"distro":        "k8s",
+		"chart_version": "0.13.0",
+	})
+	if diags := dataSourceVClusterValuesValidateRead(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if !runner.ran("helm template vcluster vcluster-k8s --repo https://charts.loft.sh --values ") {
+		t.Fatalf("expected the chart of the distro to be rendered, ran %q", runner.lines())
+	}
+	if cmd := runner.find(t, "helm template"); cmd.Args[len(cmd.Args)-2] != "--version" || cmd.Args[len(cmd.Args)-1] != "0.13.0" {
+		t.Fatalf("expected the chart version to be passed, got %q", cmd.Args)
+	}
+}
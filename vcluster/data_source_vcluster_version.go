@@ -0,0 +1,68 @@
+package vcluster
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func dataSourceVClusterVersion() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceVClusterVersionRead,
+
+		Schema: map[string]*schema.Schema{
+			"chart": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "vcluster",
+				Description: "The virtual cluster chart name to list the versions of",
+			},
+			"chart_repo": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     LoftChartRepo,
+				Description: "The virtual cluster chart repo to list the versions from",
+			},
+			"include_chart_versions": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Description: "If true the available chart versions are fetched from the chart repo",
+			},
+			"cli_version": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The version of the installed vcluster cli",
+			},
+			"chart_versions": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "The chart versions available in the chart repo, only populated when include_chart_versions is set",
+			},
+		},
+	}
+}
+
+func dataSourceVClusterVersionRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	cliVersion, diags := vclusterCLIVersion(ctx, m.(*Meta))
+	if diags.HasError() {
+		return diags
+	}
+
+	chartVersions := []string{}
+	if d.Get("include_chart_versions").(bool) {
+		versions, err := fetchChartVersions(ctx, d.Get("chart_repo").(string), d.Get("chart").(string))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		chartVersions = versions
+	}
+
+	d.SetId(cliVersion)
+	d.Set("cli_version", cliVersion)
+	d.Set("chart_versions", chartVersions)
+
+	return nil
+}
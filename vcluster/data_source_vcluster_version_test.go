@@ -0,0 +1,65 @@
+package vcluster
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// testRepoIndex is the index of a chart repo publishing two versions of the vcluster chart.
+const testRepoIndex = `apiVersion: v1
+entries:
+  vcluster:
+  - name: vcluster
+    version: 0.13.0
+  - name: vcluster
+    version: 0.12.3
+  vcluster-k8s:
+  - name: vcluster-k8s
+    version: 0.13.0
+`
+
+// testChartRepo serves the index at the root of a chart repo.
+func testChartRepo(t *testing.T, index string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/index.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(index))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestDataSourceVClusterVersionRead(t *testing.T) {
+	repo := testChartRepo(t, testRepoIndex)
+
+	meta, runner := testMeta(t, map[string]interface{}{})
+	runner.on("vcluster --version", fakeResult{stdout: "vcluster version 0.13.0\n"})
+
+	d := schema.TestResourceDataRaw(t, dataSourceVClusterVersion().Schema, map[string]interface{}{
+		"chart_repo":             repo.URL,
+		"include_chart_versions": true,
+	})
+
+	if diags := dataSourceVClusterVersionRead(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	if version := d.Get("cli_version").(string); version != "0.13.0" {
+		t.Fatalf("expected the cli version 0.13.0, got %q", version)
+	}
+
+	expected := []interface{}{"0.13.0", "0.12.3"}
+	if versions := d.Get("chart_versions").([]interface{}); !reflect.DeepEqual(versions, expected) {
+		t.Fatalf("expected the chart versions %q, got %q", expected, versions)
+	}
+}
@@ -0,0 +1,30 @@
+package vcluster
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+type crudFunc = func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics
+
+// withPhase wraps a CRUD function so that the summaries of its diagnostics name the resource and the lifecycle phase
+// they were produced in, which tells them apart when many resources are applied at once.
+func withPhase(phase string, f crudFunc) crudFunc {
+	return func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+		diags := f(ctx, d, m)
+
+		name := d.Get("name").(string)
+		if name == "" {
+			name = d.Id()
+		}
+
+		for i := range diags {
+			diags[i].Summary = fmt.Sprintf("%s (%s): %s", name, phase, diags[i].Summary)
+		}
+
+		return diags
+	}
+}
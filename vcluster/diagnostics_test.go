@@ -0,0 +1,37 @@
+package vcluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestWithPhase(t *testing.T) {
+	failing := func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+		return diag.Diagnostics{
+			{Severity: diag.Error, Summary: "vcluster create test"},
+			{Severity: diag.Warning, Summary: "deprecated flag"},
+		}
+	}
+
+	d := testVCluster(t, map[string]interface{}{"name": "test"})
+	diags := withPhase("create", failing)(context.Background(), d, nil)
+
+	expected := []string{"test (create): vcluster create test", "test (create): deprecated flag"}
+	for i, summary := range expected {
+		if diags[i].Summary != summary {
+			t.Fatalf("expected the summary %q, got %q", summary, diags[i].Summary)
+		}
+	}
+
+	// the name is not known yet while importing.
+	d = testVCluster(t, map[string]interface{}{})
+	d.SetId("imported")
+	diags = withPhase("read", failing)(context.Background(), d, nil)
+
+	if summary := diags[0].Summary; summary != "imported (read): vcluster create test" {
+		t.Fatalf("expected the summary to name the id, got %q", summary)
+	}
+}
@@ -0,0 +1,108 @@
+package vcluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// drainTimeoutDivisor bounds the drain to a share of the delete timeout, so that a hanging drain leaves the rest of it
+// to deleting the vcluster.
+const drainTimeoutDivisor = 2
+
+// vclusterKubectlArgs returns the arguments running kubectl against the vcluster through vcluster connect.
+func vclusterKubectlArgs(d *schema.ResourceData, meta *Meta, args []string) []string {
+	connectArgs := vclusterBaseArgs(d, meta, []string{
+		"connect",
+		vclusterName(d),
+	})
+
+	return append(append(connectArgs, "--", "kubectl"), args...)
+}
+
+// syncsHostNodes returns true if the applied values of the vcluster sync the real nodes of the host cluster into it,
+// instead of the fake nodes the syncer creates by default.
+func syncsHostNodes(d *schema.ResourceData) bool {
+	var values map[string]interface{}
+	if err := yaml.Unmarshal([]byte(d.Get("values_applied").(string)), &values); err != nil {
+		return false
+	}
+
+	for _, path := range []string{"sync.nodes.enabled", "sync.fromHost.nodes.enabled"} {
+		if enabled, ok := getValue(values, path).(bool); ok && enabled {
+			return true
+		}
+	}
+
+	return false
+}
+
+// drainVCluster cordons and drains the nodes of the vcluster, so that its workloads shut down gracefully before the
+// control plane is torn down. Failures are reported as warnings, as an unreachable vcluster must still be deletable.
+func drainVCluster(ctx context.Context, d *schema.ResourceData, meta *Meta) diag.Diagnostics {
+	timeout := d.Timeout(schema.TimeoutDelete) / drainTimeoutDivisor
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	diags := drainNodes(ctx, d, meta)
+	if deadline, _ := ctx.Deadline(); !time.Now().Before(deadline) {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "timed out draining the vcluster",
+			Detail:   fmt.Sprintf("The nodes of vcluster %s were not drained within %s, it is deleted without waiting for the rest of the drain.", vclusterName(d), timeout),
+		})
+	}
+
+	for i := range diags {
+		diags[i].Severity = diag.Warning
+	}
+	return diags
+}
+
+// drainNodes drains the nodes of the vcluster one after the other, each within the time left until the deadline of the
+// context.
+func drainNodes(ctx context.Context, d *schema.ResourceData, meta *Meta) diag.Diagnostics {
+	output, diags := runVCluster(ctx, meta, vclusterKubectlArgs(d, meta, []string{"get", "nodes", "--output", "name"}))
+	if diags.HasError() {
+		return diags
+	}
+
+	// the real nodes of the host are shared with its other workloads, so the pods without a controller are reported by
+	// kubectl instead of being deleted by force.
+	force := !syncsHostNodes(d)
+
+	deadline, _ := ctx.Deadline()
+	for _, line := range strings.Split(string(output), "\n") {
+		// kubectl prints the nodes as node/<name>
+		if !strings.HasPrefix(line, "node/") {
+			continue
+		}
+
+		// kubectl waits forever with a zero timeout, so the time left is rounded up to whole seconds.
+		left := time.Until(deadline)
+		if left <= 0 {
+			break
+		}
+		left = (left + time.Second - 1).Truncate(time.Second)
+
+		args := []string{
+			"drain", strings.TrimSpace(line),
+			"--ignore-daemonsets",
+			"--delete-emptydir-data",
+			fmt.Sprintf("--timeout=%s", left),
+		}
+		if force {
+			args = append(args, "--force")
+		}
+
+		_, drainDiags := runVCluster(ctx, meta, vclusterKubectlArgs(d, meta, args))
+		diags = append(diags, drainDiags...)
+	}
+
+	return diags
+}
@@ -0,0 +1,99 @@
+package vcluster
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDrainVClusterBeforeDelete(t *testing.T) {
+	cases := []struct {
+		values string
+		force  bool
+	}{
+		{force: true},
+		// the real nodes of the host are not drained by force.
+		{values: "sync:\n  nodes:\n    enabled: true\n"},
+		{values: "sync:\n  fromHost:\n    nodes:\n      enabled: true\n"},
+	}
+
+	for _, c := range cases {
+		meta, runner := testMeta(t, map[string]interface{}{})
+		runner.on("vcluster connect test -- kubectl get nodes", fakeResult{stdout: "node/a\nnode/b\n"})
+
+		d := testVCluster(t, map[string]interface{}{"name": "test", "drain_on_delete": true})
+		d.SetId("test")
+		d.Set("values_applied", c.values)
+
+		if diags := resourceVClusterDelete(context.Background(), d, meta); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		drained, deleted := -1, -1
+		for i, line := range runner.lines() {
+			switch {
+			case strings.HasPrefix(line, "vcluster connect test -- kubectl drain node/b"):
+				drained = i
+			case strings.HasPrefix(line, "vcluster delete test"):
+				deleted = i
+			}
+		}
+		if drained == -1 || deleted < drained {
+			t.Fatalf("expected the nodes to be drained before the vcluster is deleted, ran %q", runner.lines())
+		}
+
+		// the drain is bounded by half of the delete timeout.
+		args := runner.find(t, "vcluster connect test -- kubectl drain node/a").Args
+		if !hasArg(args, "--timeout=10m0s") {
+			t.Fatalf("expected the drain to time out with half of the delete timeout, got %q", args)
+		}
+		if hasArg(args, "--force") != c.force {
+			t.Fatalf("values %q: expected --force to be passed to be %t, got %q", c.values, c.force, args)
+		}
+	}
+}
+
+func TestDrainVClusterHangs(t *testing.T) {
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep is not installed")
+	}
+
+	meta, runner := testMeta(t, map[string]interface{}{})
+	runner.on("vcluster connect test -- kubectl get nodes", fakeResult{stdout: "node/a\n"})
+	meta.runner = func(cmd *exec.Cmd) error {
+		if hasArg(cmd.Args, "drain") {
+			// the drain hangs until it is killed by the context of the command.
+			cmd.Path, cmd.Args, cmd.Err = sleep, []string{"sleep", "60"}, nil
+			return cmd.Run()
+		}
+		return runner.run(cmd)
+	}
+
+	current := testVCluster(t, map[string]interface{}{"name": "test", "drain_on_delete": true})
+	current.SetId("test")
+
+	r := resourceVCluster()
+	timeout := 400 * time.Millisecond
+	r.Timeouts = &schema.ResourceTimeout{Delete: &timeout}
+	d := r.Data(current.State())
+
+	diags := resourceVClusterDelete(context.Background(), d, meta)
+	if diags.HasError() {
+		t.Fatalf("expected the hanging drain not to fail the delete, got %v", diags)
+	}
+
+	timedOut := false
+	for _, diagnostic := range diags {
+		timedOut = timedOut || diagnostic.Summary == "timed out draining the vcluster"
+	}
+	if !timedOut {
+		t.Fatalf("expected the drain to time out, got %v", diags)
+	}
+
+	runner.find(t, "vcluster delete test")
+}
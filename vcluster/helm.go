@@ -0,0 +1,299 @@
+package vcluster
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// helmBaseArgs appends the namespace and context of the vcluster using helm's flag names.
+func helmBaseArgs(d *schema.ResourceData, meta *Meta, args []string) []string {
+	if namespace := d.Get("namespace"); namespace != nil && namespace.(string) != "" {
+		args = append(args, "--namespace", namespace.(string))
+	}
+
+	if context := vclusterContext(d, meta); context != "" {
+		args = append(args, "--kube-context", context)
+	}
+
+	return args
+}
+
+// releaseValuesChecksum fetches the user supplied values of the vcluster's helm release and returns their checksum.
+func releaseValuesChecksum(ctx context.Context, d *schema.ResourceData, meta *Meta) (string, diag.Diagnostics) {
+	args := helmBaseArgs(d, meta, []string{
+		"get", "values",
+		vclusterReleaseName(d),
+		"--output", "json",
+	})
+
+	output, diags := runHelm(ctx, meta, args)
+	if diags.HasError() {
+		return "", diags
+	}
+
+	return valuesChecksum(output)
+}
+
+// valuesChecksum returns the checksum of json encoded helm values, independent of their key order and formatting.
+func valuesChecksum(values []byte) (string, diag.Diagnostics) {
+	var decoded interface{}
+	if err := json.Unmarshal(values, &decoded); err != nil {
+		return "", diag.FromErr(err)
+	}
+
+	normalized, err := json.Marshal(decoded)
+	if err != nil {
+		return "", diag.FromErr(err)
+	}
+
+	sum := sha256.Sum256(normalized)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// localChartChecksum returns the checksum of the paths and contents of the files in the chart directory. The directory
+// is walked in lexical order, so the checksum only changes when the chart does.
+func localChartChecksum(dir string) (string, error) {
+	hash := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(hash, "%s\x00%d\x00", filepath.ToSlash(rel), len(data))
+		hash.Write(data)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// recordValuesChecksum stores the checksum of the applied release values when drift detection is enabled.
+func recordValuesChecksum(ctx context.Context, d *schema.ResourceData, meta *Meta) diag.Diagnostics {
+	if !d.Get("detect_values_drift").(bool) {
+		d.Set("values_checksum", "")
+		d.Set("values_drift", false)
+		return nil
+	}
+
+	checksum, diags := releaseValuesChecksum(ctx, d, meta)
+	if diags.HasError() {
+		return diags
+	}
+
+	d.Set("values_checksum", checksum)
+	d.Set("values_drift", false)
+	return nil
+}
+
+// detectValuesDrift compares the live release values against the checksum recorded on the last apply.
+func detectValuesDrift(ctx context.Context, d *schema.ResourceData, meta *Meta) diag.Diagnostics {
+	if !d.Get("detect_values_drift").(bool) {
+		return nil
+	}
+
+	recorded := d.Get("values_checksum").(string)
+	if recorded == "" {
+		// drift detection was enabled after the last apply, so there is nothing to compare against yet.
+		return nil
+	}
+
+	checksum, diags := releaseValuesChecksum(ctx, d, meta)
+	if diags.HasError() {
+		return diags
+	}
+
+	d.Set("values_drift", checksum != recorded)
+	return nil
+}
+
+// helmHistoryEntry is a struct matching an entry of helm history's json output.
+type helmHistoryEntry struct {
+	Revision int    `json:"revision"`
+	Status   string `json:"status"`
+	Chart    string `json:"chart"`
+}
+
+// latestEntry returns the last entry of helm history's json output.
+func latestEntry(output []byte) (helmHistoryEntry, error) {
+	var history []helmHistoryEntry
+	if err := json.Unmarshal(jsonOutput(output), &history); err != nil {
+		return helmHistoryEntry{}, err
+	}
+
+	if len(history) == 0 {
+		return helmHistoryEntry{}, fmt.Errorf("the release has no history")
+	}
+
+	return history[len(history)-1], nil
+}
+
+// chartVersion returns the version of the chart of a history entry, which helm reports as <name>-<version>.
+func (e helmHistoryEntry) chartVersion() string {
+	for i := 0; i < len(e.Chart)-1; i++ {
+		if e.Chart[i] == '-' && e.Chart[i+1] >= '0' && e.Chart[i+1] <= '9' {
+			return e.Chart[i+1:]
+		}
+	}
+
+	return ""
+}
+
+// readHelmRevision records the current revision of the vcluster's helm release. Failures are reported as warnings, as
+// the revision is informational.
+func readHelmRevision(ctx context.Context, d *schema.ResourceData, meta *Meta) diag.Diagnostics {
+	args := helmBaseArgs(d, meta, []string{
+		"history",
+		vclusterReleaseName(d),
+		"--max", "1",
+		"--output", "json",
+	})
+
+	output, diags := runHelm(ctx, meta, args)
+	if diags.HasError() {
+		for i := range diags {
+			diags[i].Severity = diag.Warning
+		}
+		return diags
+	}
+
+	entry, err := latestEntry(output)
+	if err != nil {
+		return diag.Diagnostics{{Severity: diag.Warning, Summary: "unable to read the helm revision", Detail: err.Error()}}
+	}
+
+	d.Set("helm_revision", entry.Revision)
+
+	// a pinned chart version is reconciled with the live release, so that an out of band upgrade shows up in the plan
+	// instead of being silently downgraded. An unpinned version is left to the cli.
+	if chartVersion := d.Get("chart_version").(string); chartVersion != "" && entry.chartVersion() != "" &&
+		strings.TrimPrefix(chartVersion, "v") != strings.TrimPrefix(entry.chartVersion(), "v") {
+		d.Set("chart_version", entry.chartVersion())
+	}
+
+	return nil
+}
+
+// activeSyncers returns the sorted resource kinds whose syncer is enabled in the json encoded values of a release.
+func activeSyncers(values []byte) ([]string, error) {
+	var release struct {
+		Sync map[string]struct {
+			Enabled bool `json:"enabled"`
+		} `json:"sync"`
+	}
+	if err := json.Unmarshal(jsonOutput(values), &release); err != nil {
+		return nil, err
+	}
+
+	syncers := []string{}
+	for kind, syncer := range release.Sync {
+		if syncer.Enabled {
+			syncers = append(syncers, kind)
+		}
+	}
+	sort.Strings(syncers)
+
+	return syncers, nil
+}
+
+// readActiveSyncers records the syncers enabled by the computed values of the vcluster's helm release, which include
+// the chart defaults. Failures are reported as warnings, as the syncers are informational.
+func readActiveSyncers(ctx context.Context, d *schema.ResourceData, meta *Meta) diag.Diagnostics {
+	args := helmBaseArgs(d, meta, []string{
+		"get", "values",
+		vclusterReleaseName(d),
+		"--all",
+		"--output", "json",
+	})
+
+	output, diags := runHelm(ctx, meta, args)
+	if diags.HasError() {
+		for i := range diags {
+			diags[i].Severity = diag.Warning
+		}
+		return diags
+	}
+
+	syncers, err := activeSyncers(output)
+	if err != nil {
+		return diag.Diagnostics{{Severity: diag.Warning, Summary: "unable to read the active syncers", Detail: err.Error()}}
+	}
+
+	d.Set("active_syncers", syncers)
+	return nil
+}
+
+// renderVCluster renders the manifests of the vcluster with helm template, using the same values it would be created
+// with, and records them without installing anything.
+func renderVCluster(ctx context.Context, d *schema.ResourceData, meta *Meta) diag.Diagnostics {
+	values, err := composeValues(d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	valuesFile, err := writeValuesFile(values)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	defer os.Remove(valuesFile)
+
+	args := []string{"template", vclusterReleaseName(d)}
+
+	if localChartDir := d.Get("local_chart_dir").(string); localChartDir != "" {
+		args = append(args, resolvePath(meta, localChartDir))
+	} else {
+		chart := d.Get("chart").(string)
+		if chart == "" {
+			distro := strings.ToLower(vclusterDistro(d))
+			if distro == "" {
+				distro = "k3s"
+			}
+			chart = distroCharts[distro]
+		}
+
+		repo := d.Get("chart_repo").(string)
+		if repo == "" {
+			repo = LoftChartRepo
+		}
+
+		args = append(args, chart, "--repo", repo)
+
+		if chartVersion := d.Get("chart_version").(string); chartVersion != "" {
+			args = append(args, "--version", chartVersion)
+		}
+	}
+
+	args = append(args, "--namespace", vclusterNamespace(d), "--values", valuesFile)
+
+	output, diags := runHelm(ctx, meta, args)
+	if diags.HasError() {
+		return diags
+	}
+
+	d.Set("values_applied", string(values))
+	d.Set("rendered_manifests", string(output))
+	return diags
+}
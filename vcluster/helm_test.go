@@ -0,0 +1,225 @@
+package vcluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDetectValuesDrift(t *testing.T) {
+	recorded, diags := valuesChecksum([]byte(`{"syncer": {"extraArgs": ["--v=2"]}}`))
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	cases := []struct {
+		live  string
+		drift bool
+	}{
+		{live: `{"syncer":{"extraArgs":["--v=2"]}}`, drift: false},
+		{live: `{"syncer":{"extraArgs":["--v=4"]}}`, drift: true},
+	}
+
+	for _, c := range cases {
+		meta, runner := testMeta(t, map[string]interface{}{})
+		runner.on("helm get values test", fakeResult{stdout: c.live})
+
+		d := testVCluster(t, map[string]interface{}{
+			"name":                "test",
+			"detect_values_drift": true,
+		})
+		d.Set("values_checksum", recorded)
+
+		if diags := detectValuesDrift(context.Background(), d, meta); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		if drift := d.Get("values_drift").(bool); drift != c.drift {
+			t.Fatalf("live values %s: expected drift %t, got %t", c.live, c.drift, drift)
+		}
+	}
+}
+
+func TestLatestEntry(t *testing.T) {
+	output := []byte(`[{"revision":1,"updated":"2022-12-09T03:12:10.123Z","status":"superseded","chart":"vcluster-0.12.3","app_version":"0.12.3","description":"Install complete"},` +
+		`{"revision":2,"updated":"2022-12-10T03:12:10.123Z","status":"deployed","chart":"vcluster-k8s-0.13.0","app_version":"0.13.0","description":"Upgrade complete"}]`)
+
+	entry, err := latestEntry(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entry.Revision != 2 || entry.Status != "deployed" {
+		t.Fatalf("expected the deployed revision 2, got %+v", entry)
+	}
+	if version := entry.chartVersion(); version != "0.13.0" {
+		t.Fatalf("expected the chart version 0.13.0, got %q", version)
+	}
+
+	if _, err := latestEntry([]byte("[]")); err == nil {
+		t.Fatal("expected an empty history to fail")
+	}
+	if _, err := latestEntry([]byte("Error: release: not found")); err == nil {
+		t.Fatal("expected output that is not json to fail")
+	}
+}
+
+func TestReadHelmRevisionChartVersion(t *testing.T) {
+	cases := []struct {
+		chartVersion string
+		chart        string
+		expected     string
+	}{
+		// the release was upgraded out of band.
+		{chartVersion: "0.12.3", chart: "vcluster-0.13.0", expected: "0.13.0"},
+		{chartVersion: "v0.13.0", chart: "vcluster-0.13.0", expected: "v0.13.0"},
+		// an unpinned version is left to the cli.
+		{chartVersion: "", chart: "vcluster-0.13.0", expected: ""},
+		{chartVersion: "0.12.3", chart: "vcluster", expected: "0.12.3"},
+	}
+
+	for _, c := range cases {
+		meta, runner := testMeta(t, map[string]interface{}{})
+		runner.on("helm history test", fakeResult{
+			stdout: fmt.Sprintf(`[{"revision":3,"status":"deployed","chart":%q}]`, c.chart),
+		})
+
+		d := testVCluster(t, map[string]interface{}{"name": "test", "chart_version": c.chartVersion})
+		if diags := readHelmRevision(context.Background(), d, meta); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		if revision := d.Get("helm_revision").(int); revision != 3 {
+			t.Errorf("%s: expected the revision 3, got %d", c.chart, revision)
+		}
+		if chartVersion := d.Get("chart_version").(string); chartVersion != c.expected {
+			t.Errorf("%s pinned to %q: expected the chart version %q, got %q", c.chart, c.chartVersion, c.expected, chartVersion)
+		}
+	}
+}
+
+func TestLocalChartChecksum(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checksum := func() string {
+		t.Helper()
+		sum, err := localChartChecksum(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sum
+	}
+
+	write("Chart.yaml", "name: vcluster\nversion: 0.13.0\n")
+	write("templates/statefulset.yaml", "kind: StatefulSet\n")
+
+	initial := checksum()
+	if again := checksum(); again != initial {
+		t.Fatalf("expected the checksum of an unchanged chart to be stable, got %s and %s", initial, again)
+	}
+
+	write("templates/statefulset.yaml", "kind: Deployment\n")
+	edited := checksum()
+	if edited == initial {
+		t.Fatal("expected editing a file to change the checksum")
+	}
+
+	write("templates/service.yaml", "kind: Service\n")
+	if added := checksum(); added == edited {
+		t.Fatal("expected adding a file to change the checksum")
+	}
+
+	if _, err := localChartChecksum(filepath.Join(dir, "missing")); err == nil {
+		t.Fatal("expected a missing chart directory to fail")
+	}
+}
+
+func TestActiveSyncers(t *testing.T) {
+	output := []byte(`{
+  "sync": {
+    "configmaps": {"all": false, "enabled": true},
+    "fake-nodes": {"enabled": true},
+    "ingresses": {"enabled": false},
+    "nodes": {"enabled": false, "syncAllNodes": false},
+    "persistentvolumeclaims": {"enabled": true},
+    "secrets": {"all": false, "enabled": true}
+  },
+  "syncer": {"replicas": 1}
+}`)
+
+	syncers, err := activeSyncers(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"configmaps", "fake-nodes", "persistentvolumeclaims", "secrets"}
+	if !reflect.DeepEqual(syncers, expected) {
+		t.Fatalf("expected %q, got %q", expected, syncers)
+	}
+
+	if syncers, err := activeSyncers([]byte(`{"syncer": {"replicas": 1}}`)); err != nil || len(syncers) != 0 {
+		t.Fatalf("expected no syncers without sync values, got %q, %v", syncers, err)
+	}
+
+	if _, err := activeSyncers([]byte("Error: release: not found")); err == nil {
+		t.Fatal("expected output that is not json to fail")
+	}
+}
+
+func TestResourceVClusterRenderOnly(t *testing.T) {
+	meta, runner := testMeta(t, map[string]interface{}{})
+	runner.on("helm template test vcluster-k8s", fakeResult{stdout: "---\nkind: StatefulSet\n"})
+
+	d := testVCluster(t, map[string]interface{}{
+		"name":          "test",
+		"namespace":     "team",
+		"distro":        "k8s",
+		"chart_version": "0.13.0",
+		"render_only":   true,
+	})
+
+	if diags := resourceVClusterCreate(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	args := runner.find(t, "helm template test").Args
+	expected := "helm template test vcluster-k8s --repo https://charts.loft.sh --version 0.13.0 --namespace team --values"
+	if line := strings.Join(args, " "); !strings.HasPrefix(line, expected) {
+		t.Fatalf("expected the chart to be rendered with %q, ran %q", expected, line)
+	}
+	for _, line := range runner.lines() {
+		if !strings.HasPrefix(line, "helm template") {
+			t.Fatalf("expected only the chart to be rendered, ran %q", line)
+		}
+	}
+
+	if manifests := d.Get("rendered_manifests").(string); manifests != "---\nkind: StatefulSet\n" {
+		t.Fatalf("expected the rendered manifests, got %q", manifests)
+	}
+	if d.Id() != "test" || d.Get("values_applied").(string) == "" {
+		t.Fatalf("expected the rendered vcluster to be recorded, got the id %q", d.Id())
+	}
+
+	if diags := resourceVClusterRead(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if diags := resourceVClusterDelete(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if lines := runner.lines(); len(lines) != 1 {
+		t.Fatalf("expected reading and deleting the rendered vcluster to run nothing, ran %q", lines)
+	}
+}
@@ -0,0 +1,63 @@
+package vcluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// reconcileIsolate reflects whether the isolation resources created by the isolate option exist in the namespace of
+// the vcluster. When only some of them exist the state is left untouched and a warning is returned instead.
+func reconcileIsolate(ctx context.Context, d *schema.ResourceData, meta *Meta, namespace string) diag.Diagnostics {
+	args := []string{
+		"get", "resourcequotas,networkpolicies",
+		"--namespace", namespace,
+		"--output", "name",
+	}
+
+	if context := vclusterContext(d, meta); context != "" {
+		args = append(args, "--context", context)
+	}
+
+	output, diags := runKubectl(ctx, meta, args)
+	if diags.HasError() {
+		for i := range diags {
+			diags[i].Severity = diag.Warning
+		}
+		return diags
+	}
+
+	releaseName := vclusterReleaseName(d)
+	expected := map[string]bool{
+		fmt.Sprintf("resourcequota/%s-quota", releaseName):                       false,
+		fmt.Sprintf("networkpolicy.networking.k8s.io/%s-workloads", releaseName): false,
+	}
+
+	found := 0
+	for _, line := range strings.Split(string(output), "\n") {
+		if _, ok := expected[strings.TrimSpace(line)]; ok {
+			expected[strings.TrimSpace(line)] = true
+			found++
+		}
+	}
+
+	switch found {
+	case 0:
+		d.Set("isolate", false)
+	case len(expected):
+		d.Set("isolate", true)
+	default:
+		return diag.Diagnostics{
+			{
+				Severity: diag.Warning,
+				Summary:  "unable to determine whether the vcluster is isolated",
+				Detail:   fmt.Sprintf("only some of the isolation resources exist in namespace %s: %v", namespace, expected),
+			},
+		}
+	}
+
+	return nil
+}
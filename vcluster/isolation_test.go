@@ -0,0 +1,55 @@
+package vcluster
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReconcileIsolate(t *testing.T) {
+	cases := []struct {
+		name     string
+		listed   string
+		isolated bool
+		warns    bool
+	}{
+		{
+			name:     "absent",
+			listed:   "",
+			isolated: false,
+		},
+		{
+			name:     "present",
+			listed:   "resourcequota/test-quota\nnetworkpolicy.networking.k8s.io/test-workloads\n",
+			isolated: true,
+		},
+		{
+			name:     "partial",
+			listed:   "resourcequota/test-quota\n",
+			isolated: true,
+			warns:    true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			meta, runner := testMeta(t, map[string]interface{}{})
+			runner.on("kubectl get resourcequotas,networkpolicies --namespace vcluster-test", fakeResult{stdout: c.listed})
+
+			// the state says the vcluster is isolated.
+			d := testVCluster(t, map[string]interface{}{"name": "test", "isolate": true})
+
+			diags := reconcileIsolate(context.Background(), d, meta, "vcluster-test")
+			if diags.HasError() {
+				t.Fatalf("unexpected diagnostics: %v", diags)
+			}
+
+			if warns := len(diags) > 0; warns != c.warns {
+				t.Fatalf("expected a warning %t, got %v", c.warns, diags)
+			}
+
+			if isolated := d.Get("isolate").(bool); isolated != c.isolated {
+				t.Fatalf("expected isolate to be %t, got %t", c.isolated, isolated)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package vcluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// minJSONLogsVersion is the first version of the vcluster cli supporting --log-output json.
+var minJSONLogsVersion = version.Must(version.NewVersion("0.12.0"))
+
+// logEntry is a struct matching a line of the vcluster cli's json log output.
+type logEntry struct {
+	Level   string `json:"level"`
+	Message string `json:"msg"`
+}
+
+// parseLogEntry parses a line of json log output, returning false if the line is not a log entry.
+func parseLogEntry(line []byte) (logEntry, bool) {
+	var entry logEntry
+	if err := json.Unmarshal(bytes.TrimSpace(line), &entry); err != nil || entry.Level == "" {
+		return logEntry{}, false
+	}
+
+	return entry, true
+}
+
+// supportsJSONLogs returns true if the vcluster cli supports structured log output.
+func supportsJSONLogs(ctx context.Context, meta *Meta) bool {
+	cliVersion, diags := vclusterCLIVersion(ctx, meta)
+	if diags.HasError() {
+		return false
+	}
+
+	v, err := version.NewVersion(cliVersion)
+	if err != nil {
+		log.Printf("[WARN] unable to parse vcluster version %q, falling back to text logs: %v", cliVersion, err)
+		return false
+	}
+
+	return v.GreaterThanOrEqual(minJSONLogsVersion)
+}
+
+// jsonLogDiagnostics converts the warnings and errors in the json log output of a failed command into diagnostics
+// summarized by the command.
+func jsonLogDiagnostics(summary string, output []byte) diag.Diagnostics {
+	var diags diag.Diagnostics
+	for _, line := range bytes.Split(output, []byte("\n")) {
+		entry, ok := parseLogEntry(line)
+		if !ok {
+			continue
+		}
+
+		switch strings.ToLower(entry.Level) {
+		case "warn", "warning":
+			diags = append(diags, diag.Diagnostic{Severity: diag.Warning, Summary: summary, Detail: entry.Message})
+		case "error", "fatal", "panic":
+			diags = append(diags, diag.Diagnostic{Severity: diag.Error, Summary: summary, Detail: entry.Message})
+		}
+	}
+
+	return diags
+}
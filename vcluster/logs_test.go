@@ -0,0 +1,29 @@
+package vcluster
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+func TestJSONLogDiagnostics(t *testing.T) {
+	output := []byte(`{"level":"info","ts":1670555530.123,"msg":"create vcluster test"}
+{"level":"warn","ts":1670555531.456,"msg":"flag --expose-local is deprecated"}
+not a log entry
+{"msg":"no level"}
+
+{"level":"ERROR","ts":1670555532.789,"msg":"release vcluster-test failed"}
+{"level":"fatal","ts":1670555533.012,"msg":"context deadline exceeded"}
+`)
+
+	expected := diag.Diagnostics{
+		{Severity: diag.Warning, Summary: "vcluster create test", Detail: "flag --expose-local is deprecated"},
+		{Severity: diag.Error, Summary: "vcluster create test", Detail: "release vcluster-test failed"},
+		{Severity: diag.Error, Summary: "vcluster create test", Detail: "context deadline exceeded"},
+	}
+
+	if diags := jsonLogDiagnostics("vcluster create test", output); !reflect.DeepEqual(diags, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, diags)
+	}
+}
@@ -0,0 +1,205 @@
+package vcluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// kubernetesClientset returns a client for the host cluster configured by the kubernetes block of the provider, in the
+// context of the vcluster, with the exec_env of the resource merged into the environment of its exec block.
+func kubernetesClientset(d *schema.ResourceData, meta *Meta) (kubernetes.Interface, error) {
+	kubeConfig, err := newKubeConfig(meta.data, nil, vclusterContext(d, meta))
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := kubeConfig.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if config.ExecProvider != nil {
+		config.ExecProvider.Env = mergeExecEnv(config.ExecProvider.Env, expandStringMap(d.Get("exec_env").(map[string]interface{})))
+	}
+
+	if meta.clientset != nil {
+		return meta.clientset(config)
+	}
+
+	return kubernetes.NewForConfig(config)
+}
+
+// applyManagedNamespace creates the namespace of the vcluster, or updates its labels and annotations once it exists.
+// Creating fails when the namespace already exists, as it is deleted with the vcluster and could hold other workloads.
+// Updates are merged into the labels and annotations of the namespace, so that only the ones previously configured are
+// removed.
+func applyManagedNamespace(ctx context.Context, d *schema.ResourceData, meta *Meta) diag.Diagnostics {
+	managed, ok := firstBlock(d, "managed_namespace")
+	if !ok {
+		return nil
+	}
+
+	clientset, err := kubernetesClientset(d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	name := vclusterNamespace(d)
+	labels := expandStringMap(managed["labels"].(map[string]interface{}))
+	annotations := expandStringMap(managed["annotations"].(map[string]interface{}))
+
+	if d.Id() == "" {
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Labels:      labels,
+				Annotations: annotations,
+			},
+		}
+
+		_, err = clientset.CoreV1().Namespaces().Create(ctx, namespace, metav1.CreateOptions{})
+		if errors.IsAlreadyExists(err) {
+			return diag.Diagnostics{
+				{
+					Severity: diag.Error,
+					Summary:  fmt.Sprintf("namespace %s already exists", name),
+					Detail:   "managed_namespace creates the namespace and deletes it with the vcluster, so it cannot be used with an existing namespace. Remove managed_namespace to install the vcluster into it.",
+				},
+			}
+		}
+		return diag.FromErr(err)
+	}
+
+	old, _ := d.GetChange("managed_namespace")
+	if len(old.([]interface{})) == 0 || old.([]interface{})[0] == nil {
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  "managed_namespace can only be set when the vcluster is created",
+				Detail:   fmt.Sprintf("namespace %s was not created by the provider, so it is not managed by it.", name),
+			},
+		}
+	}
+	previous := old.([]interface{})[0].(map[string]interface{})
+
+	existing, err := clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	existing.Labels = mergeMetadata(existing.Labels, expandStringMap(previous["labels"].(map[string]interface{})), labels)
+	existing.Annotations = mergeMetadata(existing.Annotations, expandStringMap(previous["annotations"].(map[string]interface{})), annotations)
+	_, err = clientset.CoreV1().Namespaces().Update(ctx, existing, metav1.UpdateOptions{})
+	return diag.FromErr(err)
+}
+
+// mergeMetadata returns the labels or annotations of an object with the configured ones applied, removing the ones that
+// were previously configured but no longer are. The ones set by others are kept.
+func mergeMetadata(current, previous, configured map[string]string) map[string]string {
+	merged := map[string]string{}
+	for key, value := range current {
+		if _, removed := previous[key]; !removed {
+			merged[key] = value
+		}
+	}
+
+	for key, value := range configured {
+		merged[key] = value
+	}
+
+	return merged
+}
+
+// deleteManagedNamespace deletes the namespace of the vcluster if it is managed by the provider.
+func deleteManagedNamespace(ctx context.Context, d *schema.ResourceData, meta *Meta) diag.Diagnostics {
+	if _, ok := firstBlock(d, "managed_namespace"); !ok {
+		return nil
+	}
+
+	clientset, err := kubernetesClientset(d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	err = clientset.CoreV1().Namespaces().Delete(ctx, vclusterNamespace(d), metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+// namespaceExists returns true if the namespace of the vcluster exists in the host cluster. It is looked up with kubectl
+// in the context of the vcluster, so that it is resolved through the same kubeconfig as the clis, and a namespace is only
+// reported missing when that cluster does not have it.
+func namespaceExists(ctx context.Context, d *schema.ResourceData, meta *Meta) (bool, diag.Diagnostics) {
+	name := vclusterNamespace(d)
+	args := []string{
+		"get", "namespace", name,
+		"--ignore-not-found",
+		"--output", "name",
+	}
+
+	if context := vclusterContext(d, meta); context != "" {
+		args = append(args, "--context", context)
+	}
+
+	output, diags := runKubectl(ctx, meta, args)
+	if diags.HasError() {
+		return false, diags
+	}
+
+	return strings.Contains(string(output), "namespace/"+name), diags
+}
+
+// reconcileCreatedNamespace clears the namespace_created_by_provider marker when the namespace was removed out of band,
+// so that it is not deleted on destroy. Failures are reported as warnings, as the marker is kept as is.
+func reconcileCreatedNamespace(ctx context.Context, d *schema.ResourceData, meta *Meta) diag.Diagnostics {
+	if !d.Get("namespace_created_by_provider").(bool) {
+		return nil
+	}
+
+	exists, diags := namespaceExists(ctx, d, meta)
+	if diags.HasError() {
+		for i := range diags {
+			diags[i].Severity = diag.Warning
+		}
+		return diags
+	}
+
+	d.Set("namespace_created_by_provider", exists)
+	return diags
+}
+
+// mergeExecEnv returns the exec environment with the overrides applied, the overrides win over existing variables.
+func mergeExecEnv(env []clientcmdapi.ExecEnvVar, overrides map[string]string) []clientcmdapi.ExecEnvVar {
+	merged := []clientcmdapi.ExecEnvVar{}
+	for _, v := range env {
+		if _, ok := overrides[v.Name]; !ok {
+			merged = append(merged, v)
+		}
+	}
+
+	for _, name := range mapKeys(overrides) {
+		merged = append(merged, clientcmdapi.ExecEnvVar{Name: name, Value: overrides[name]})
+	}
+
+	return merged
+}
+
+func expandStringMap(m map[string]interface{}) map[string]string {
+	result := make(map[string]string, len(m))
+	for k, v := range m {
+		result[k] = v.(string)
+	}
+	return result
+}
@@ -0,0 +1,268 @@
+package vcluster
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+// testKubeConfig is a kube config with a context for each of two host clusters.
+const testKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: one
+  cluster:
+    server: https://one.example.com
+- name: two
+  cluster:
+    server: https://two.example.com
+users:
+- name: admin
+  user:
+    token: secret
+contexts:
+- name: one
+  context:
+    cluster: one
+    user: admin
+- name: two
+  context:
+    cluster: two
+    user: admin
+current-context: one
+`
+
+// testClientset configures the provider with the kube config of two host clusters, building the clients of the host
+// cluster as fakes over the objects. The hosts the clients were built for are recorded.
+func testClientset(t *testing.T, objects ...runtime.Object) (*Meta, *fake.Clientset, *[]string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(testKubeConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	meta, _ := testMeta(t, map[string]interface{}{
+		"kubernetes": []interface{}{map[string]interface{}{"config_path": path}},
+	})
+
+	clientset := fake.NewSimpleClientset(objects...)
+	hosts := []string{}
+	meta.clientset = func(config *rest.Config) (kubernetes.Interface, error) {
+		hosts = append(hosts, config.Host)
+		return clientset, nil
+	}
+
+	return meta, clientset, &hosts
+}
+
+func TestApplyManagedNamespaceCreate(t *testing.T) {
+	meta, clientset, hosts := testClientset(t)
+
+	d := testVCluster(t, map[string]interface{}{
+		"name":    "test",
+		"context": "two",
+		"managed_namespace": []interface{}{map[string]interface{}{
+			"labels": map[string]interface{}{"team": "a"},
+		}},
+	})
+
+	if diags := applyManagedNamespace(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	namespace, err := clientset.CoreV1().Namespaces().Get(context.Background(), "vcluster-test", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]string{"team": "a"}; !reflect.DeepEqual(namespace.Labels, expected) {
+		t.Fatalf("expected the labels %v, got %v", expected, namespace.Labels)
+	}
+
+	if expected := []string{"https://two.example.com"}; !reflect.DeepEqual(*hosts, expected) {
+		t.Fatalf("expected the namespace to be created in the context of the vcluster, got %q", *hosts)
+	}
+}
+
+func TestApplyManagedNamespaceExisting(t *testing.T) {
+	meta, clientset, _ := testClientset(t, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "vcluster-test", Labels: map[string]string{"owner": "someone-else"}},
+	})
+
+	d := testVCluster(t, map[string]interface{}{
+		"name": "test",
+		"managed_namespace": []interface{}{map[string]interface{}{
+			"labels": map[string]interface{}{"team": "a"},
+		}},
+	})
+
+	if diags := applyManagedNamespace(context.Background(), d, meta); !diags.HasError() {
+		t.Fatal("expected creating an existing namespace to fail")
+	}
+
+	namespace, err := clientset.CoreV1().Namespaces().Get(context.Background(), "vcluster-test", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]string{"owner": "someone-else"}; !reflect.DeepEqual(namespace.Labels, expected) {
+		t.Fatalf("expected the labels of the existing namespace to be kept, got %v", namespace.Labels)
+	}
+}
+
+func TestApplyManagedNamespaceUpdate(t *testing.T) {
+	meta, clientset, _ := testClientset(t, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "vcluster-test",
+			Labels: map[string]string{"team": "a", "tier": "b", "injected": "true"},
+		},
+	})
+
+	_, d := testVClusterPlan(t, meta, map[string]interface{}{
+		"name": "test",
+		"managed_namespace": []interface{}{map[string]interface{}{
+			"labels": map[string]interface{}{"team": "a", "tier": "b"},
+		}},
+	}, map[string]interface{}{
+		"name": "test",
+		"managed_namespace": []interface{}{map[string]interface{}{
+			"labels": map[string]interface{}{"team": "c"},
+		}},
+	})
+
+	if diags := applyManagedNamespace(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	namespace, err := clientset.CoreV1().Namespaces().Get(context.Background(), "vcluster-test", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]string{"team": "c", "injected": "true"}; !reflect.DeepEqual(namespace.Labels, expected) {
+		t.Fatalf("expected the labels %v, got %v", expected, namespace.Labels)
+	}
+}
+
+func TestDeleteManagedNamespace(t *testing.T) {
+	meta, clientset, _ := testClientset(t, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "vcluster-test"}})
+
+	d := testVCluster(t, map[string]interface{}{
+		"name": "test",
+		"managed_namespace": []interface{}{map[string]interface{}{
+			"labels": map[string]interface{}{"team": "a"},
+		}},
+	})
+	d.SetId("test")
+
+	// deleting twice succeeds, as the namespace is already gone the second time.
+	for i := 0; i < 2; i++ {
+		if diags := deleteManagedNamespace(context.Background(), d, meta); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+	}
+
+	_, err := clientset.CoreV1().Namespaces().Get(context.Background(), "vcluster-test", metav1.GetOptions{})
+	if !errors.IsNotFound(err) {
+		t.Fatalf("expected the namespace to be deleted, got %v", err)
+	}
+}
+
+func TestManagedNamespaceFailedInstall(t *testing.T) {
+	meta, clientset, _ := testClientset(t)
+
+	runner := &fakeRunner{}
+	meta.runner = runner.run
+	runner.on("vcluster create test", fakeResult{stderr: "install failed\n", err: exitError(1)})
+
+	d := testVCluster(t, map[string]interface{}{
+		"name": "test",
+		"managed_namespace": []interface{}{map[string]interface{}{
+			"labels": map[string]interface{}{"team": "a"},
+		}},
+	})
+
+	if diags := resourceVClusterCreate(context.Background(), d, meta); !diags.HasError() {
+		t.Fatal("expected the failed install to fail the create")
+	}
+
+	_, err := clientset.CoreV1().Namespaces().Get(context.Background(), "vcluster-test", metav1.GetOptions{})
+	if !errors.IsNotFound(err) {
+		t.Fatalf("expected the namespace of the failed install to be deleted, got %v", err)
+	}
+}
+
+func TestManagedNamespaceRemoved(t *testing.T) {
+	meta, _ := testMeta(t, map[string]interface{}{})
+
+	diff, _ := testVClusterPlan(t, meta, map[string]interface{}{
+		"name": "test",
+		"managed_namespace": []interface{}{map[string]interface{}{
+			"labels": map[string]interface{}{"team": "a"},
+		}},
+	}, map[string]interface{}{
+		"name": "test",
+	})
+
+	if !diff.RequiresNew() {
+		t.Fatal("expected removing the managed namespace to replace the vcluster")
+	}
+}
+
+func TestNamespaceCreatedByProvider(t *testing.T) {
+	meta, runner := testMeta(t, map[string]interface{}{})
+	// the namespace does not exist before the vcluster is created.
+	runner.on("kubectl get namespace team", fakeResult{})
+
+	d := testVCluster(t, map[string]interface{}{
+		"name":                   "test",
+		"namespace":              "team",
+		"create_namespace":       true,
+		"skip_read_after_create": true,
+	})
+
+	if diags := resourceVClusterCreate(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if !d.Get("namespace_created_by_provider").(bool) {
+		t.Fatal("expected the namespace to be recorded as created by the provider")
+	}
+
+	// the lookup failing keeps the marker.
+	meta, runner = testMeta(t, map[string]interface{}{})
+	runner.on("kubectl get namespace team", fakeResult{stdout: "Unable to connect to the server\n", err: exitError(1)})
+
+	diags := reconcileCreatedNamespace(context.Background(), d, meta)
+	if len(diags) == 0 || diags.HasError() {
+		t.Fatalf("expected the failed lookup to be a warning, got %v", diags)
+	}
+	if !d.Get("namespace_created_by_provider").(bool) {
+		t.Fatal("expected the marker to be kept when the namespace cannot be read")
+	}
+
+	// the namespace was removed out of band.
+	meta, runner = testMeta(t, map[string]interface{}{})
+	runner.on("kubectl get namespace team", fakeResult{})
+
+	if diags := reconcileCreatedNamespace(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if d.Get("namespace_created_by_provider").(bool) {
+		t.Fatal("expected the marker to be cleared once the namespace is gone")
+	}
+
+	if diags := resourceVClusterDelete(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if args := runner.find(t, "vcluster delete test").Args; hasArg(args, "--delete-namespace") {
+		t.Fatalf("expected the removed namespace not to be deleted, got %q", args)
+	}
+}
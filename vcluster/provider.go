@@ -8,14 +8,56 @@ package vcluster
 
 import (
 	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
 
 	"github.com/hashicorp/go-cty/cty"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/mitchellh/go-homedir"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
 type Meta struct {
 	data *schema.ResourceData
+
+	binaryPath          string
+	disableColor        bool
+	disableTelemetry    bool
+	defaultContext      string
+	workingDir          string
+	jsonLogs            bool
+	keepValuesOnFailure bool
+	forwardStderr       bool
+	baseValues          []string
+
+	// env is added to the environment of the commands, it holds the exec_env of a resource.
+	env map[string]string
+
+	// clusterAliases maps friendly names resources can use as their context to the contexts they stand for.
+	clusterAliases map[string]string
+
+	// acceptableExitCodes are the non-zero exit codes of commands treated as success.
+	acceptableExitCodes map[int]bool
+
+	versions *versionCache
+
+	// commands bounds the number of concurrently running commands, it is nil when unbounded.
+	commands chan struct{}
+
+	// runner runs the commands, it is nil to run them with exec.
+	runner runner
+
+	// clientset builds the clients of the host cluster, it is nil to build them with client-go.
+	clientset func(config *rest.Config) (kubernetes.Interface, error)
+
+	// dial connects to the api servers of the vclusters, it is nil to connect over the network.
+	dial func(ctx context.Context, network, address string) (net.Conn, error)
 }
 
 func Provider() *schema.Provider {
@@ -28,9 +70,85 @@ func Provider() *schema.Provider {
 				Description: "Kubernetes configuration.",
 				Elem:        kubernetesResource(),
 			},
+			"disable_color": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "If true the vcluster cli is run without colored or interactive output.",
+			},
+			"binary_path": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Default:          "vcluster",
+				Description:      "The path of the vcluster cli, looked up in the PATH when it is only a name.",
+				ValidateDiagFunc: validateExecutable,
+			},
+			"working_dir": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The directory relative paths, such as local_chart_dir and set_file, are resolved against. It is also the working directory of the commands run by the provider. Defaults to the working directory of terraform.",
+			},
+			"parallelism": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "The maximum number of commands the provider runs concurrently across all resources, 0 means unlimited. Lowering it reduces the load on the host cluster when many vclusters are applied at once, at the cost of a longer apply.",
+			},
+			"json_logs": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "If true the vcluster cli logs as json, which is parsed into the diagnostics of failed commands. Ignored when the cli does not support it.",
+			},
+			"keep_values_on_failure": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "If true the temporary helm values files of failed commands are kept, and their paths logged, for debugging.",
+			},
+			"base_values": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Raw yaml helm values applied to every vcluster, merged in order. The attributes and extra_values of a vcluster override them.",
+			},
+			"cluster_aliases": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Friendly names for kubernetes config contexts, which the context of resources can be set to instead of the context itself. When set, a context that is neither an alias nor a context of the kubeconfig fails the plan.",
+			},
+			"forward_stderr": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "If true the stderr of successful commands is logged as warnings, so that deprecation notices of the clis are visible during normal applies. Otherwise it is only reported when a command fails.",
+			},
+			"acceptable_exit_codes": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeInt},
+				Description: "Non-zero exit codes of vcluster create and vcluster connect that are treated as success, their output is reported as a warning. Only 0 is treated as success by default, and always for the other commands run by the provider.",
+			},
+			"disable_telemetry": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "If true the telemetry of the vcluster cli is disabled for every command run by the provider.",
+			},
 		},
 		ResourcesMap: map[string]*schema.Resource{
-			"vcluster_vcluster": resourceVCluster(),
+			"vcluster_vcluster":                   resourceVCluster(),
+			"vcluster_connect":                    resourceConnect(),
+			"vcluster_platform_connected_cluster": resourcePlatformConnectedCluster(),
+		},
+		DataSourcesMap: map[string]*schema.Resource{
+			"vcluster_version":             dataSourceVClusterVersion(),
+			"vcluster_status":              dataSourceVClusterStatus(),
+			"vcluster_values_validate":     dataSourceVClusterValuesValidate(),
+			"vcluster_provider_config":     dataSourceVClusterProviderConfig(),
+			"vcluster_distro_capabilities": dataSourceVClusterDistroCapabilities(),
 		},
 	}
 	p.ConfigureContextFunc = func(ctx context.Context, rd *schema.ResourceData) (interface{}, diag.Diagnostics) {
@@ -42,10 +160,139 @@ func Provider() *schema.Provider {
 
 func providerConfigure(d *schema.ResourceData, terraformVersion string) (interface{}, diag.Diagnostics) {
 	m := &Meta{
-		data: d,
+		data:                d,
+		binaryPath:          d.Get("binary_path").(string),
+		disableColor:        d.Get("disable_color").(bool),
+		disableTelemetry:    d.Get("disable_telemetry").(bool),
+		workingDir:          d.Get("working_dir").(string),
+		jsonLogs:            d.Get("json_logs").(bool),
+		keepValuesOnFailure: d.Get("keep_values_on_failure").(bool),
+		forwardStderr:       d.Get("forward_stderr").(bool),
+		baseValues:          expandStringSlice(d.Get("base_values").([]interface{})),
+		versions:            &versionCache{versions: map[string]string{}},
+		acceptableExitCodes: map[int]bool{},
+		clusterAliases:      expandStringMap(d.Get("cluster_aliases").(map[string]interface{})),
+	}
+
+	for _, code := range d.Get("acceptable_exit_codes").(*schema.Set).List() {
+		m.acceptableExitCodes[code.(int)] = true
+	}
+
+	if parallelism := d.Get("parallelism").(int); parallelism > 0 {
+		m.commands = make(chan struct{}, parallelism)
+	}
+
+	if context, ok := k8sGetOk(d, "config_context"); ok {
+		m.defaultContext = context.(string)
+	}
+
+	diags := validateKubernetesBlock(d)
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	if _, err := parseBaseValues(m.baseValues); err != nil {
+		return nil, append(diags, diag.FromErr(err)...)
+	}
+
+	return m, diags
+}
+
+// kubeconfigContexts returns the names of the contexts in the kubeconfig files configured by the kubernetes block, or the
+// default kubeconfig files when none are.
+func kubeconfigContexts(d *schema.ResourceData) (map[string]bool, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+
+	if v, ok := k8sGetOk(d, "config_path"); ok && v != "" {
+		path, err := homedir.Expand(v.(string))
+		if err != nil {
+			return nil, err
+		}
+		rules.ExplicitPath = path
+	} else if v, ok := k8sGetOk(d, "config_paths"); ok {
+		rules.Precedence = nil
+		for _, p := range v.([]interface{}) {
+			path, err := homedir.Expand(p.(string))
+			if err != nil {
+				return nil, err
+			}
+			rules.Precedence = append(rules.Precedence, path)
+		}
+	}
+
+	config, err := rules.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	contexts := map[string]bool{}
+	for name := range config.Contexts {
+		contexts[name] = true
+	}
+
+	return contexts, nil
+}
+
+// checkContextAlias verifies that the context of a resource is either one of the cluster aliases, or a context of the
+// kubeconfig, so that a mistyped alias fails the plan. It is only checked when cluster aliases are configured.
+func checkContextAlias(d *schema.ResourceDiff, meta *Meta) error {
+	contextName := d.Get("context").(string)
+	if len(meta.clusterAliases) == 0 || contextName == "" {
+		return nil
+	}
+
+	if _, ok := meta.clusterAliases[contextName]; ok {
+		return nil
+	}
+
+	contexts, err := kubeconfigContexts(meta.data)
+	if err != nil {
+		return err
+	}
+
+	if !contexts[contextName] {
+		return fmt.Errorf("context %q is neither one of the cluster_aliases (%s) nor a context of the kubeconfig", contextName, strings.Join(mapKeys(meta.clusterAliases), ", "))
+	}
+
+	return nil
+}
+
+// validateKubernetesBlock catches inconsistent combinations of the kubernetes block, which would otherwise only fail
+// once a command is run against the host cluster.
+func validateKubernetesBlock(d *schema.ResourceData) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	host, hasHost := k8sGetOk(d, "host")
+	_, hasCA := k8sGetOk(d, "cluster_ca_certificate")
+	// the default of insecure is read from the environment as a string.
+	insecure, _ := k8sGetOk(d, "insecure")
+	if insecureEnv, ok := insecure.(string); ok {
+		insecure, _ = strconv.ParseBool(insecureEnv)
+	}
+	// a kubeconfig may provide the certificate authority of the host.
+	_, hasConfigPath := k8sGetOk(d, "config_path")
+	_, hasConfigPaths := k8sGetOk(d, "config_paths")
+	if hasHost && !hasCA && !hasConfigPath && !hasConfigPaths && insecure != true && !strings.HasPrefix(host.(string), "http://") {
+		diags = append(diags, diag.Diagnostic{
+			Severity:      diag.Warning,
+			Summary:       "kubernetes host is set without a cluster_ca_certificate",
+			Detail:        "The certificate of the host is only verified against the system certificate authorities. Set cluster_ca_certificate if it is signed by a private certificate authority, or set insecure to true to skip the verification.",
+			AttributePath: cty.GetAttrPath("kubernetes").IndexInt(0).GetAttr("cluster_ca_certificate"),
+		})
+	}
+
+	_, hasToken := k8sGetOk(d, "token")
+	_, hasClientCert := k8sGetOk(d, "client_certificate")
+	if hasToken && hasClientCert {
+		diags = append(diags, diag.Diagnostic{
+			Severity:      diag.Error,
+			Summary:       "kubernetes token and client_certificate are both set",
+			Detail:        "Only one way of authenticating can be used. Remove either token or client_certificate and client_key.",
+			AttributePath: cty.GetAttrPath("kubernetes").IndexInt(0).GetAttr("token"),
+		})
 	}
 
-	return m, nil
+	return diags
 }
 
 func kubernetesResource() *schema.Resource {
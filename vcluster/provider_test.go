@@ -0,0 +1,154 @@
+package vcluster
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestValidateKubernetesBlock(t *testing.T) {
+	cases := []struct {
+		name       string
+		kubernetes map[string]interface{}
+		severity   diag.Severity
+		summary    string
+		path       cty.Path
+	}{
+		{
+			name:       "host without cluster_ca_certificate",
+			kubernetes: map[string]interface{}{"host": "https://host.example.com", "token": "secret"},
+			severity:   diag.Warning,
+			summary:    "kubernetes host is set without a cluster_ca_certificate",
+			path:       cty.GetAttrPath("kubernetes").IndexInt(0).GetAttr("cluster_ca_certificate"),
+		},
+		{
+			name: "token with client_certificate",
+			kubernetes: map[string]interface{}{
+				"host":                   "https://host.example.com",
+				"cluster_ca_certificate": "ca",
+				"token":                  "secret",
+				"client_certificate":     "cert",
+				"client_key":             "key",
+			},
+			severity: diag.Error,
+			summary:  "kubernetes token and client_certificate are both set",
+			path:     cty.GetAttrPath("kubernetes").IndexInt(0).GetAttr("token"),
+		},
+		{
+			name:       "host with cluster_ca_certificate",
+			kubernetes: map[string]interface{}{"host": "https://host.example.com", "cluster_ca_certificate": "ca", "token": "secret"},
+		},
+		{
+			name:       "insecure host",
+			kubernetes: map[string]interface{}{"host": "https://host.example.com", "insecure": true},
+		},
+		{
+			name:       "plain http host",
+			kubernetes: map[string]interface{}{"host": "http://localhost:8080"},
+		},
+		{
+			name:       "host from a kubeconfig",
+			kubernetes: map[string]interface{}{"host": "https://host.example.com", "config_path": "~/.kube/config"},
+		},
+	}
+
+	for _, c := range cases {
+		d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+			"kubernetes": []interface{}{c.kubernetes},
+		})
+
+		diags := validateKubernetesBlock(d)
+		if c.summary == "" {
+			if len(diags) != 0 {
+				t.Errorf("%s: unexpected diagnostics: %v", c.name, diags)
+			}
+			continue
+		}
+
+		if len(diags) != 1 {
+			t.Errorf("%s: expected one diagnostic, got %v", c.name, diags)
+			continue
+		}
+		if diags[0].Severity != c.severity || diags[0].Summary != c.summary || !diags[0].AttributePath.Equals(c.path) {
+			t.Errorf("%s: expected %q at %#v, got %q at %#v", c.name, c.summary, c.path, diags[0].Summary, diags[0].AttributePath)
+		}
+	}
+}
+
+func TestProviderConfigureKubernetesBlock(t *testing.T) {
+	// a warning does not stop the provider from being configured.
+	m, diags := providerConfigure(schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"kubernetes": []interface{}{map[string]interface{}{"host": "https://host.example.com", "token": "secret"}},
+	}), "")
+	if m == nil || diags.HasError() || len(diags) != 1 {
+		t.Fatalf("expected the provider to be configured with a warning, got %v", diags)
+	}
+
+	m, diags = providerConfigure(schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"kubernetes": []interface{}{map[string]interface{}{
+			"host":                   "https://host.example.com",
+			"cluster_ca_certificate": "ca",
+			"token":                  "secret",
+			"client_certificate":     "cert",
+		}},
+	}), "")
+	if m != nil || !diags.HasError() {
+		t.Fatalf("expected the provider configuration to fail, got %v", diags)
+	}
+}
+
+func TestCheckContextAlias(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(testKubeConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		aliases  map[string]interface{}
+		context  string
+		expected string
+		error    string
+	}{
+		{aliases: map[string]interface{}{"staging": "two"}, context: "staging", expected: "two"},
+		{aliases: map[string]interface{}{"staging": "two"}, context: "one", expected: "one"},
+		{
+			aliases: map[string]interface{}{"staging": "two", "production": "one"},
+			context: "prod",
+			error:   `context "prod" is neither one of the cluster_aliases (production, staging) nor a context of the kubeconfig`,
+		},
+		// contexts are only checked when aliases are configured.
+		{context: "prod", expected: "prod"},
+	}
+
+	for _, c := range cases {
+		meta, _ := testMeta(t, map[string]interface{}{
+			"kubernetes":      []interface{}{map[string]interface{}{"config_path": path}},
+			"cluster_aliases": c.aliases,
+		})
+
+		config := map[string]interface{}{"name": "test", "context": c.context}
+		_, err := resourceVCluster().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config), meta)
+		if c.error != "" {
+			if err == nil || err.Error() != c.error {
+				t.Errorf("context %q: expected the error %q, got %v", c.context, c.error, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("context %q: unexpected error %v", c.context, err)
+			continue
+		}
+
+		args := vclusterBaseArgs(testVCluster(t, config), meta, []string{"list"})
+		if expected := []string{"list", "--context", c.expected}; !reflect.DeepEqual(args, expected) {
+			t.Errorf("context %q: expected %q, got %q", c.context, expected, args)
+		}
+	}
+}
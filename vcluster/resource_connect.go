@@ -0,0 +1,149 @@
+package vcluster
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func resourceConnect() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: withPhase("create", resourceConnectCreate),
+		ReadContext:   withPhase("read", resourceConnectRead),
+		DeleteContext: withPhase("delete", resourceConnectDelete),
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:        schema.TypeString,
+				Description: "The name of the vcluster to connect to",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"context": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "The kubernetes config context to use. Takes precedence over the config_context of the provider",
+			},
+			"kube_context_name": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringMatch(kubeContextNameRegexp, "must be a kube config context name without whitespace"),
+				Description:  "The name of the context added to the kube config for the vcluster",
+			},
+			"namespace": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "The kubernetes namespace to use",
+			},
+		},
+	}
+}
+
+// connectNamespace returns the namespace of the vcluster, which the cli derives from its name when none is configured.
+func connectNamespace(d *schema.ResourceData) string {
+	if namespace := d.Get("namespace").(string); namespace != "" {
+		return namespace
+	}
+
+	return "vcluster-" + d.Get("name").(string)
+}
+
+// backgroundProxyPrefix returns the prefix of the name of the docker container the vcluster cli runs the background
+// proxy in. The cli names it vcluster_<name>_<namespace>_<host context>_background_proxy.
+func backgroundProxyPrefix(d *schema.ResourceData) string {
+	return fmt.Sprintf("vcluster_%s_%s_", d.Get("name").(string), connectNamespace(d))
+}
+
+// backgroundProxies lists the docker containers running the background proxy of the vcluster, whatever host context
+// it was connected through.
+func backgroundProxies(ctx context.Context, d *schema.ResourceData, meta *Meta) ([]string, diag.Diagnostics) {
+	prefix := backgroundProxyPrefix(d)
+	output, diags := runCommand(ctx, meta, "docker", []string{"ps", "--all", "--filter", "name=" + prefix, "--format", "{{.Names}}"})
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	// docker matches the filter anywhere in the name, so the names are matched again.
+	names := []string{}
+	for _, name := range strings.Fields(string(output)) {
+		if strings.HasPrefix(name, prefix) && strings.HasSuffix(name, "_background_proxy") {
+			names = append(names, name)
+		}
+	}
+
+	return names, diags
+}
+
+// isConnectionContext returns true if the kube context is the one added to the kube config for the connection. The cli
+// names it vcluster_<name>_<namespace>_<host context> unless kube_context_name is set.
+func isConnectionContext(d *schema.ResourceData, context string) bool {
+	if contextName := d.Get("kube_context_name").(string); contextName != "" {
+		return context == contextName
+	}
+
+	return strings.HasPrefix(context, fmt.Sprintf("vcluster_%s_%s_", d.Get("name").(string), connectNamespace(d)))
+}
+
+func resourceConnectCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	args := vclusterBaseArgs(d, m.(*Meta), []string{
+		"connect",
+		d.Get("name").(string),
+		"--update-current=true",
+		"--background-proxy=true",
+	})
+
+	if contextName := d.Get("kube_context_name"); contextName != nil && contextName.(string) != "" {
+		args = append(args, fmt.Sprintf("--kube-config-context-name=%s", contextName.(string)))
+	}
+
+	_, diags := runVCluster(ctx, m.(*Meta), args)
+	if diags.HasError() {
+		return diags
+	}
+
+	d.SetId(d.Get("name").(string))
+	return nil
+}
+
+func resourceConnectRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	return nil
+}
+
+func resourceConnectDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	// disconnect restores the kube context that was active before connecting, but acts on the current context, so it
+	// only runs while the current context is still the one of this connection.
+	current, diags := runKubectl(ctx, m.(*Meta), []string{"config", "current-context"})
+	if !diags.HasError() && isConnectionContext(d, strings.TrimSpace(string(current))) {
+		output, diags := runVCluster(ctx, m.(*Meta), []string{"disconnect"})
+		if diags.HasError() && !strings.Contains(string(output), "is not a virtual cluster context") {
+			return diags
+		}
+	}
+
+	// the background proxy only runs in docker, so there is nothing to reap without it.
+	if _, err := exec.LookPath("docker"); err != nil {
+		return nil
+	}
+
+	// the background proxy is not always stopped by disconnect, so make sure it does not outlive the connection.
+	names, diags := backgroundProxies(ctx, d, m.(*Meta))
+	if diags.HasError() {
+		return diags
+	}
+
+	for _, name := range names {
+		if _, diags := runCommand(ctx, m.(*Meta), "docker", []string{"rm", "--force", name}); diags.HasError() {
+			return diags
+		}
+	}
+
+	return nil
+}
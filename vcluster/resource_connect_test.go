@@ -0,0 +1,130 @@
+package vcluster
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// testPath replaces the PATH with a directory containing the executables.
+func testPath(t *testing.T, executables ...string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	for _, name := range executables {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Setenv("PATH", dir)
+}
+
+func TestResourceConnectDelete(t *testing.T) {
+	cases := []struct {
+		name        string
+		config      map[string]interface{}
+		current     string
+		docker      bool
+		disconnects bool
+		containers  string
+		list        string
+		reap        []string
+	}{
+		{
+			name:        "current connection",
+			config:      map[string]interface{}{"name": "test"},
+			current:     "vcluster_test_vcluster-test_kind-host",
+			docker:      true,
+			disconnects: true,
+			containers:  "vcluster_test_vcluster-test_kind-host_background_proxy\nmyvcluster_test_vcluster-test_kind-host_background_proxy\n",
+			list:        "docker ps --all --filter name=vcluster_test_vcluster-test_ --format {{.Names}}",
+			reap:        []string{"docker rm --force vcluster_test_vcluster-test_kind-host_background_proxy"},
+		},
+		{
+			name:        "named connection",
+			config:      map[string]interface{}{"name": "test", "namespace": "team", "kube_context_name": "test"},
+			current:     "test",
+			docker:      true,
+			disconnects: true,
+			containers:  "vcluster_test_team_kind-host_background_proxy\n",
+			list:        "docker ps --all --filter name=vcluster_test_team_ --format {{.Names}}",
+			reap:        []string{"docker rm --force vcluster_test_team_kind-host_background_proxy"},
+		},
+		{
+			name:        "context switched",
+			config:      map[string]interface{}{"name": "test"},
+			current:     "vcluster_other_vcluster-other_kind-host",
+			docker:      true,
+			disconnects: false,
+			// the proxies of every host context are reaped.
+			containers: "vcluster_test_vcluster-test_kind-host_background_proxy\nvcluster_test_vcluster-test_kind-other_background_proxy\n",
+			list:       "docker ps --all --filter name=vcluster_test_vcluster-test_ --format {{.Names}}",
+			reap: []string{
+				"docker rm --force vcluster_test_vcluster-test_kind-host_background_proxy",
+				"docker rm --force vcluster_test_vcluster-test_kind-other_background_proxy",
+			},
+		},
+		{
+			name:        "proxy stopped",
+			config:      map[string]interface{}{"name": "test"},
+			current:     "vcluster_test_vcluster-test_kind-host",
+			docker:      true,
+			disconnects: true,
+			list:        "docker ps --all --filter name=vcluster_test_vcluster-test_ --format {{.Names}}",
+		},
+		{
+			name:        "without docker",
+			config:      map[string]interface{}{"name": "test"},
+			current:     "vcluster_test_vcluster-test_kind-host",
+			docker:      false,
+			disconnects: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if c.docker {
+				testPath(t, "docker")
+			} else {
+				testPath(t)
+			}
+
+			meta, runner := testMeta(t, map[string]interface{}{})
+			runner.on("kubectl config current-context", fakeResult{stdout: c.current + "\n"})
+			runner.on("docker ps", fakeResult{stdout: c.containers})
+
+			d := schema.TestResourceDataRaw(t, resourceConnect().Schema, c.config)
+			d.SetId("test")
+
+			if diags := resourceConnectDelete(context.Background(), d, meta); diags.HasError() {
+				t.Fatalf("unexpected diagnostics: %v", diags)
+			}
+
+			if runner.ran("vcluster disconnect") != c.disconnects {
+				t.Fatalf("expected disconnect to run %t, ran %q", c.disconnects, runner.lines())
+			}
+
+			docker := []string{}
+			for _, line := range runner.lines() {
+				if strings.HasPrefix(line, "docker") {
+					docker = append(docker, line)
+				}
+			}
+
+			// the proxies are listed before the ones of the connection are removed.
+			expected := []string{}
+			if c.list != "" {
+				expected = append(append(expected, c.list), c.reap...)
+			}
+			if !reflect.DeepEqual(docker, expected) {
+				t.Fatalf("expected docker to run %q, ran %q", expected, docker)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package vcluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// resourcePlatformConnectedCluster manages a host cluster connected to the vcluster platform. The cli must be logged in
+// to the platform, e.g. with vcluster platform login, as the provider has no platform configuration of its own. The
+// login is checked by listing the connected clusters before every operation.
+func resourcePlatformConnectedCluster() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: withPhase("create", resourcePlatformConnectedClusterCreate),
+		ReadContext:   withPhase("read", resourcePlatformConnectedClusterRead),
+		DeleteContext: withPhase("delete", resourcePlatformConnectedClusterDelete),
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+		Description: "A host cluster connected to the vcluster platform. The provider has no platform block, the vcluster cli is used with the platform it is logged in to, e.g. with vcluster platform login. The login is checked before every operation by listing the connected clusters, which fails with an error when the cli is not logged in. A cluster that is already connected is not adopted, it must be imported instead, so that destroying the resource only disconnects clusters it manages.",
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:             schema.TypeString,
+				Description:      "The name of the cluster in the platform",
+				Required:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: validateResourceName,
+			},
+			"context": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "The kubernetes config context of the cluster to connect. Takes precedence over the config_context of the provider",
+			},
+			"display_name": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "The name of the cluster shown in the platform",
+			},
+			"status": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The connection status of the cluster as reported by the platform",
+			},
+		},
+	}
+}
+
+// PlatformClusterEntry is a struct matching the results of the platform list clusters operation's json output.
+type PlatformClusterEntry struct {
+	Name   string
+	Status string
+}
+
+// listPlatformClusters lists the clusters connected to the platform. Listing fails unless the cli is logged in to the
+// platform, which is reported as the likely cause.
+func listPlatformClusters(ctx context.Context, meta *Meta) ([]PlatformClusterEntry, diag.Diagnostics) {
+	output, diags := runVCluster(ctx, meta, []string{"platform", "list", "clusters", "--output", "json"})
+	if diags.HasError() {
+		return nil, append(diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  "unable to list the clusters of the vcluster platform",
+			Detail:   "The vcluster cli must be logged in to the platform, e.g. with vcluster platform login, before clusters can be connected to it.",
+		}}, diags...)
+	}
+
+	var entries []PlatformClusterEntry
+	if err := json.Unmarshal(jsonOutput(output), &entries); err != nil {
+		return nil, diag.FromErr(err)
+	}
+
+	return entries, diags
+}
+
+// findPlatformCluster returns the cluster connected to the platform with the name.
+func findPlatformCluster(ctx context.Context, meta *Meta, name string) (PlatformClusterEntry, bool, diag.Diagnostics) {
+	entries, diags := listPlatformClusters(ctx, meta)
+	if diags.HasError() {
+		return PlatformClusterEntry{}, false, diags
+	}
+
+	for _, entry := range entries {
+		if entry.Name == name {
+			return entry, true, diags
+		}
+	}
+
+	return PlatformClusterEntry{}, false, diags
+}
+
+func resourcePlatformConnectedClusterCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	meta := m.(*Meta)
+	name := d.Get("name").(string)
+
+	// a cluster that is already connected is not adopted, as destroying the resource would disconnect it.
+	_, found, diags := findPlatformCluster(ctx, meta, name)
+	if diags.HasError() {
+		return diags
+	}
+
+	if found {
+		return append(diags, diag.Diagnostic{
+			Severity:      diag.Error,
+			Summary:       "the cluster is already connected to the vcluster platform",
+			Detail:        fmt.Sprintf("The cluster %s is already connected to the platform. Import it with terraform import to manage it with this resource.", name),
+			AttributePath: cty.GetAttrPath("name"),
+		})
+	}
+
+	args := []string{
+		"platform", "add", "cluster",
+		name,
+	}
+
+	if contextName := vclusterContext(d, meta); contextName != "" {
+		args = append(args, "--context", contextName)
+	}
+
+	if displayName := d.Get("display_name").(string); displayName != "" {
+		args = append(args, "--display-name", displayName)
+	}
+
+	_, addDiags := runVCluster(ctx, meta, args)
+	diags = append(diags, addDiags...)
+	if diags.HasError() {
+		return diags
+	}
+
+	d.SetId(name)
+	return append(diags, resourcePlatformConnectedClusterRead(ctx, d, m)...)
+}
+
+func resourcePlatformConnectedClusterRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	entry, found, diags := findPlatformCluster(ctx, m.(*Meta), d.Id())
+	if diags.HasError() {
+		return diags
+	}
+
+	if !found {
+		d.SetId("")
+		return diags
+	}
+
+	d.Set("name", entry.Name)
+	d.Set("status", entry.Status)
+	return diags
+}
+
+func resourcePlatformConnectedClusterDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	meta := m.(*Meta)
+
+	// a cluster that was disconnected out of band is already gone.
+	_, found, diags := findPlatformCluster(ctx, meta, d.Id())
+	if diags.HasError() || !found {
+		return diags
+	}
+
+	_, deleteDiags := runVCluster(ctx, meta, []string{"platform", "delete", "cluster", d.Id()})
+	return append(diags, deleteDiags...)
+}
@@ -0,0 +1,122 @@
+package vcluster
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// testPlatformCluster returns the data of a vcluster_platform_connected_cluster resource with the raw configuration.
+func testPlatformCluster(t *testing.T, raw map[string]interface{}) *schema.ResourceData {
+	t.Helper()
+
+	return schema.TestResourceDataRaw(t, resourcePlatformConnectedCluster().Schema, raw)
+}
+
+func TestResourcePlatformConnectedClusterLifecycle(t *testing.T) {
+	meta, runner := testMeta(t, map[string]interface{}{})
+
+	// the cluster is only listed once it was added.
+	added := false
+	meta.runner = func(cmd *exec.Cmd) error {
+		line := strings.Join(cmd.Args, " ")
+		switch {
+		case strings.HasPrefix(line, "vcluster platform add cluster"):
+			added = true
+		case strings.HasPrefix(line, "vcluster platform delete cluster"):
+			added = false
+		case strings.HasPrefix(line, "vcluster platform list clusters") && added:
+			io.WriteString(cmd.Stdout, `[{"Name": "prod", "Status": "Connected"}]`)
+		case strings.HasPrefix(line, "vcluster platform list clusters"):
+			io.WriteString(cmd.Stdout, `[]`)
+		}
+		return runner.run(cmd)
+	}
+
+	d := testPlatformCluster(t, map[string]interface{}{
+		"name":         "prod",
+		"context":      "kind-prod",
+		"display_name": "Production",
+	})
+
+	if diags := resourcePlatformConnectedClusterCreate(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	runner.find(t, "vcluster platform add cluster prod --context kind-prod --display-name Production")
+	if d.Id() != "prod" || d.Get("status").(string) != "Connected" {
+		t.Fatalf("expected the connected cluster to be read, got the id %q and status %q", d.Id(), d.Get("status").(string))
+	}
+
+	if diags := resourcePlatformConnectedClusterDelete(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	runner.find(t, "vcluster platform delete cluster prod")
+
+	// the cluster is gone once it was disconnected.
+	if diags := resourcePlatformConnectedClusterRead(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if d.Id() != "" {
+		t.Fatalf("expected the disconnected cluster to be removed from the state, got the id %q", d.Id())
+	}
+}
+
+func TestResourcePlatformConnectedClusterAlreadyConnected(t *testing.T) {
+	meta, runner := testMeta(t, map[string]interface{}{})
+	runner.on("vcluster platform list clusters", fakeResult{stdout: `[{"Name": "prod", "Status": "Connected"}]`})
+
+	d := testPlatformCluster(t, map[string]interface{}{"name": "prod"})
+	diags := resourcePlatformConnectedClusterCreate(context.Background(), d, meta)
+	if !diags.HasError() || diags[0].Summary != "the cluster is already connected to the vcluster platform" {
+		t.Fatalf("expected the connected cluster to be reported, got %v", diags)
+	}
+	if runner.ran("vcluster platform add") || d.Id() != "" {
+		t.Fatalf("expected the connected cluster not to be adopted, ran %q", runner.lines())
+	}
+
+	// the cluster is read once it was imported.
+	d.SetId("prod")
+	if diags := resourcePlatformConnectedClusterRead(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if d.Get("name").(string) != "prod" || d.Get("status").(string) != "Connected" {
+		t.Fatalf("expected the imported cluster to be read, got the name %q and status %q", d.Get("name").(string), d.Get("status").(string))
+	}
+}
+
+func TestResourcePlatformConnectedClusterDeleteGone(t *testing.T) {
+	meta, runner := testMeta(t, map[string]interface{}{})
+	runner.on("vcluster platform list clusters", fakeResult{stdout: `[{"Name": "staging", "Status": "Connected"}]`})
+
+	d := testPlatformCluster(t, map[string]interface{}{"name": "prod"})
+	d.SetId("prod")
+
+	if diags := resourcePlatformConnectedClusterDelete(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if runner.ran("vcluster platform delete") {
+		t.Fatalf("expected a cluster that is no longer connected not to be deleted, ran %q", runner.lines())
+	}
+}
+
+func TestResourcePlatformConnectedClusterNotLoggedIn(t *testing.T) {
+	meta, runner := testMeta(t, map[string]interface{}{})
+	runner.on("vcluster platform list clusters", fakeResult{
+		stderr: "fatal   not logged in, please run vcluster platform login\n",
+		err:    exitError(1),
+	})
+
+	d := testPlatformCluster(t, map[string]interface{}{"name": "prod"})
+	diags := resourcePlatformConnectedClusterCreate(context.Background(), d, meta)
+	if !diags.HasError() || diags[0].Summary != "unable to list the clusters of the vcluster platform" {
+		t.Fatalf("expected the missing platform login to be reported, got %v", diags)
+	}
+	if runner.ran("vcluster platform add") || d.Id() != "" {
+		t.Fatalf("expected nothing to be connected, ran %q", runner.lines())
+	}
+}
@@ -3,29 +3,120 @@ package vcluster
 import (
 	"context"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
-	"os/exec"
+	"os"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	k8svalidation "k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
 )
 
 var distroKinds = []string{"k0s", "k8s", "k3s"}
 
+var editions = []string{"oss", "pro"}
+
+var secretSyncModes = []string{"off", "to-host", "from-host"}
+
+var rbacScopes = []string{"cluster", "namespace"}
+
+// proOnlyAttributes are the attributes modeling features only available in the pro edition of vcluster.
+var proOnlyAttributes = []string{"webhooks", "auto_delete_after", "platform_project", "registry"}
+
+var webhookSyncModes = []string{"disabled", "sync", "fake"}
+
+// upgradeAttributes are the attributes rendered into the values or the flags of the vcluster, changing any of them in
+// place upgrades its helm release. The other attributes only affect how the provider reads, connects to or deletes it.
+var upgradeAttributes = []string{
+	"advertise_address",
+	"anti_affinity",
+	"atomic",
+	"audit",
+	"cert_renewal",
+	"chart",
+	"chart_repo",
+	"chart_version",
+	"context",
+	"default_storage_class",
+	"disable_coredns",
+	"disable_ingress_sync",
+	"edition",
+	"egress_proxy",
+	"enforce_node_selector",
+	"expose",
+	"expose_local",
+	"extra_values",
+	"extra_volume_mounts",
+	"extra_volumes",
+	"from_host_sync",
+	"host_rewrite",
+	"ingress",
+	"init_containers",
+	"isolate",
+	"kubernetes_version",
+	"local_chart_checksum",
+	"local_chart_dir",
+	"metrics",
+	"namespace",
+	"node_port",
+	"pdb",
+	"pod_annotations",
+	"post_renderer",
+	"priority_class_name",
+	"probes",
+	"registry",
+	"sa_token_audience",
+	"scheduler_name",
+	"secret_sync",
+	"set_file",
+	"set_literal",
+	"sync_exclude_selector",
+	"syncer_log_level",
+	"termination_grace_period_seconds",
+	"values_drift",
+	"values_from_secret",
+	"webhooks",
+}
+
+var kubeContextNameRegexp = regexp.MustCompile(`^[^\s]+$`)
+
+var domainRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+var namespacedNameRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?/[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+
+var intOrPercentRegexp = regexp.MustCompile(`^[0-9]+%?$`)
+
 const LoftChartRepo = "https://charts.loft.sh"
 
+// deleteProgressInterval is the interval the status of a vcluster is logged in while it is being deleted.
+var deleteProgressInterval = 30 * time.Second
+
+// readRetryMaxBackoff bounds the backoff of listing a newly created vcluster until it is included in the list.
+const readRetryMaxBackoff = 8 * time.Second
+
 func resourceVCluster() *schema.Resource {
 	return &schema.Resource{
-		CreateContext: resourceVClusterCreate,
-		ReadContext:   resourceVClusterRead,
-		UpdateContext: resourceVClusterUpdate,
-		DeleteContext: resourceVClusterDelete,
+		CreateContext: withPhase("create", resourceVClusterCreate),
+		ReadContext:   withPhase("read", resourceVClusterRead),
+		UpdateContext: withPhase("update", resourceVClusterUpdate),
+		DeleteContext: withPhase("delete", resourceVClusterDelete),
+		CustomizeDiff: resourceVClusterCustomizeDiff,
 		Importer: &schema.ResourceImporter{
 			StateContext: schema.ImportStatePassthroughContext,
 		},
+		Timeouts: &schema.ResourceTimeout{
+			Delete: schema.DefaultTimeout(20 * time.Minute),
+		},
 
 		Schema: map[string]*schema.Schema{
 			"name": {
@@ -34,11 +125,38 @@ func resourceVCluster() *schema.Resource {
 				Required:    true,
 				ForceNew:    true,
 			},
+			"cluster_name": {
+				Type:        schema.TypeString,
+				Description: "The name of the vcluster in the host cluster, when it has to differ from name. Defaults to name",
+				Optional:    true,
+				ForceNew:    true,
+			},
 			"distro": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ValidateFunc:  validation.StringInSlice(distroKinds, true),
+				ForceNew:      true,
+				ConflictsWith: []string{"distro_candidates"},
+			},
+			"distro_candidates": {
+				Type:          schema.TypeList,
+				Description:   "A prioritized list of distros, the first one supported by the host cluster at create time is used. A distro is supported when its chart is published in the chart repo and supports the kubernetes version of the host. Ignored after create, as the distro cannot change",
+				Optional:      true,
+				ForceNew:      true,
+				Elem:          &schema.Schema{Type: schema.TypeString, ValidateFunc: validation.StringInSlice(distroKinds, true)},
+				ConflictsWith: []string{"distro"},
+			},
+			"effective_distro": {
+				Type:        schema.TypeString,
+				Description: "The distro the vcluster was created with, either distro or the one chosen from distro_candidates",
+				Computed:    true,
+			},
+			"edition": {
 				Type:         schema.TypeString,
 				Optional:     true,
-				ValidateFunc: validation.StringInSlice(distroKinds, true),
-				ForceNew:     true,
+				Default:      "oss",
+				ValidateFunc: validation.StringInSlice(editions, false),
+				Description:  "The vcluster edition, oss or pro. Attributes of pro only features are rejected for the oss edition",
 			},
 			"extra_values": {
 				Type:        schema.TypeList,
@@ -46,6 +164,19 @@ func resourceVCluster() *schema.Resource {
 				Description: "List of values in raw yaml format to pass to vcluster.",
 				Elem:        &schema.Schema{Type: schema.TypeString},
 			},
+			"set_file": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Description: "Map of helm value keys to paths of files whose contents are set as the value, like helm's --set-file.",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"set_literal": {
+				Type:             schema.TypeMap,
+				Optional:         true,
+				Description:      "Map of helm value keys to values that are set verbatim as strings, like helm's --set-literal, so that dots, commas and the like are not interpreted.",
+				Elem:             &schema.Schema{Type: schema.TypeString},
+				ValidateDiagFunc: validation.MapKeyMatch(regexp.MustCompile(`^[^=\s]+$`), "must be a helm value key without spaces or ="),
+			},
 			"chart": {
 				Type:        schema.TypeString,
 				Optional:    true,
@@ -67,22 +198,635 @@ func resourceVCluster() *schema.Resource {
 				Optional:      true,
 				Description:   "The virtual cluster local chart dir to use",
 			},
+			"local_chart_checksum": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The checksum of the files in local_chart_dir, edits to the chart change it and upgrade the vcluster",
+			},
 			"kubernetes_version": {
 				Type:        schema.TypeString,
 				Description: "The kubernetes version to use (e.g. v1.20). Patch versions are not supported",
 				Optional:    true,
 			},
+			"service_cidr": {
+				Type:         schema.TypeString,
+				Description:  "The service CIDR of the vcluster, it must match the service CIDR of the host cluster",
+				Optional:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.IsCIDR,
+			},
+			"cluster_domain": {
+				Type:         schema.TypeString,
+				Description:  "The cluster domain of the vcluster (e.g. cluster.local)",
+				Optional:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringMatch(domainRegexp, "must be a valid domain name"),
+			},
+			"advertise_address": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Description:  "The address the api server of the vcluster advertises to clients, also used as the server of the generated kubeconfig",
+				ValidateFunc: validation.Any(validation.IsIPAddress, validation.StringMatch(domainRegexp, "must be a valid IP address or host name")),
+			},
 			"create_namespace": {
 				Type:        schema.TypeBool,
 				Description: "If true the namespace will be created if it does not exist",
 				Optional:    true,
 			},
+			"namespace_created_by_provider": {
+				Type:        schema.TypeBool,
+				Description: "True if the namespace did not exist and was created with the vcluster because of create_namespace, in which case it is deleted with the vcluster",
+				Computed:    true,
+			},
 			"disable_ingress_sync": {
 				Type:        schema.TypeBool,
 				Description: "If true the virtual cluster will not sync any ingresses",
 				Optional:    true,
 			},
-			// UpdateCurrent?
+			"disable_coredns": {
+				Type:        schema.TypeBool,
+				Description: "If true the virtual cluster is created without its CoreDNS, for setups that provide their own DNS",
+				Optional:    true,
+				ValidateDiagFunc: func(val interface{}, key cty.Path) diag.Diagnostics {
+					if !val.(bool) {
+						return nil
+					}
+
+					return diag.Diagnostics{{
+						Severity:      diag.Warning,
+						Summary:       "CoreDNS is disabled",
+						Detail:        "Pods in the vcluster cannot resolve service names unless DNS is provided by other means.",
+						AttributePath: key,
+					}}
+				},
+			},
+			"sa_token_audience": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringIsNotWhiteSpace,
+				Description:  "The audience of the projected service account token of the control plane, e.g. for workload identity federation",
+			},
+			"restart_generation": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Description: "Changing this value, e.g. by incrementing it, restarts the control plane on the next apply without recreating the vcluster",
+			},
+			"sync_exclude_selector": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ValidateDiagFunc: validateLabelSelector,
+				Description:      "A label selector, e.g. team!=infra, excluding the matching resources from being synced",
+			},
+			"syncer_log_level": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IntBetween(0, 10),
+				Description:  "The log verbosity of the syncer, between 0 and 10",
+			},
+			"webhooks": {
+				Type:        schema.TypeList,
+				MaxItems:    1,
+				Optional:    true,
+				Description: "How admission webhook configurations created in the virtual cluster are handled by the syncer.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"validating": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "disabled",
+							ValidateFunc: validation.StringInSlice(webhookSyncModes, false),
+							Description:  "The sync mode of validating webhook configurations, one of disabled, sync or fake",
+						},
+						"mutating": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "disabled",
+							ValidateFunc: validation.StringInSlice(webhookSyncModes, false),
+							Description:  "The sync mode of mutating webhook configurations, one of disabled, sync or fake",
+						},
+					},
+				},
+			},
+			"host_rewrite": {
+				Type:        schema.TypeList,
+				MaxItems:    1,
+				Optional:    true,
+				Description: "Rewrites the host names of services of the vcluster to services of the host cluster when they are synced.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"rule": {
+							Type:     schema.TypeList,
+							Required: true,
+							MinItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"virtual": {
+										Type:         schema.TypeString,
+										Required:     true,
+										ValidateFunc: validation.StringMatch(namespacedNameRegexp, "must be in the form namespace/name"),
+										Description:  "The service in the vcluster in the form namespace/name",
+									},
+									"host": {
+										Type:         schema.TypeString,
+										Required:     true,
+										ValidateFunc: validation.StringMatch(namespacedNameRegexp, "must be in the form namespace/name"),
+										Description:  "The service in the host cluster it is rewritten to in the form namespace/name",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			"secret_sync": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice(secretSyncModes, false),
+				Description:  "The direction secrets are synced in: off, to-host syncs the secrets of the vcluster into the host cluster, from-host the secrets of the host cluster into the vcluster. Takes precedence over secrets in from_host_sync",
+			},
+			"from_host_sync": {
+				Type:        schema.TypeList,
+				MaxItems:    1,
+				Optional:    true,
+				Description: "Resources synced from the host cluster into the virtual cluster.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"kinds": {
+							Type:     schema.TypeList,
+							Required: true,
+							Elem: &schema.Schema{
+								Type:         schema.TypeString,
+								ValidateFunc: validation.StringInSlice(mapKeys(fromHostSyncKinds), false),
+							},
+							Description: "The resource kinds to sync from the host, any of configmaps, secrets, storageclasses, ingressclasses or priorityclasses",
+						},
+					},
+				},
+			},
+			"ingress": {
+				Type:        schema.TypeList,
+				MaxItems:    1,
+				Optional:    true,
+				Description: "An ingress exposing the vcluster api server through an ingress controller of the host cluster. This is unrelated to disable_ingress_sync, which controls syncing the ingresses created inside the vcluster.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"enabled": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     true,
+							Description: "If true the ingress is created",
+						},
+						"host": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validation.StringIsNotWhiteSpace,
+							Description:  "The host the vcluster api server is served on",
+						},
+						"class": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validation.StringIsNotWhiteSpace,
+							Description:  "The ingress class of the ingress",
+						},
+						"annotations": {
+							Type:        schema.TypeMap,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "The annotations of the ingress",
+						},
+						"tls_secret": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							RequiredWith: []string{"ingress.0.host"},
+							ValidateFunc: validation.StringIsNotWhiteSpace,
+							Description:  "The secret holding the tls certificate for the host",
+						},
+					},
+				},
+			},
+			"priority_class_name": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ValidateDiagFunc: validateResourceName,
+				Description:      "The priority class of the control plane pods, protecting them from preemption",
+			},
+			"enforce_node_selector": {
+				Type:             schema.TypeMap,
+				Optional:         true,
+				Elem:             &schema.Schema{Type: schema.TypeString},
+				ValidateDiagFunc: validateLabels,
+				Description:      "A node selector the syncer enforces on every pod synced to the host cluster, so that they only land on the matching nodes",
+			},
+			"scheduler_name": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ValidateDiagFunc: validateResourceName,
+				Description:      "The scheduler of the host cluster that schedules the pods synced from the vcluster, defaults to the default scheduler",
+			},
+			"termination_grace_period_seconds": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "How long the control plane pods are given to shut down before they are killed, defaults to the one of the chart",
+			},
+			"pod_annotations": {
+				Type:             schema.TypeMap,
+				Optional:         true,
+				Elem:             &schema.Schema{Type: schema.TypeString},
+				ValidateDiagFunc: validateAnnotationKeys,
+				Description:      "Annotations added to the control plane pods, e.g. to toggle sidecar injection",
+			},
+			"init_containers": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "Init containers run in the control plane pods before the vcluster starts, e.g. to seed configuration.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:             schema.TypeString,
+							Required:         true,
+							ValidateDiagFunc: validateResourceName,
+							Description:      "The name of the init container",
+						},
+						"image": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.StringIsNotWhiteSpace,
+							Description:  "The image of the init container",
+						},
+						"command": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "The entrypoint of the init container, defaults to the one of the image",
+						},
+						"args": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "The arguments of the entrypoint",
+						},
+						"env": {
+							Type:        schema.TypeMap,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "The environment variables of the init container",
+						},
+					},
+				},
+			},
+			"extra_volumes": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "Volumes added to the control plane pods, mounted with extra_volume_mounts. Exactly one of config_map, secret or empty_dir must be set.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:             schema.TypeString,
+							Required:         true,
+							ValidateDiagFunc: validateResourceName,
+							Description:      "The name of the volume",
+						},
+						"config_map": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "The name of a config map in the namespace of the vcluster backing the volume",
+						},
+						"secret": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "The name of a secret in the namespace of the vcluster backing the volume",
+						},
+						"empty_dir": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Description: "If true the volume is an empty directory",
+						},
+					},
+				},
+			},
+			"extra_volume_mounts": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "Mounts of extra_volumes into the control plane container.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The name of the volume in extra_volumes",
+						},
+						"mount_path": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.StringMatch(regexp.MustCompile(`^/`), "must be an absolute path"),
+							Description:  "The path the volume is mounted at",
+						},
+						"sub_path": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "The path within the volume to mount",
+						},
+						"read_only": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Description: "If true the volume is mounted read only",
+						},
+					},
+				},
+			},
+			"anti_affinity": {
+				Type:        schema.TypeList,
+				MaxItems:    1,
+				Optional:    true,
+				Description: "Spreads the control plane pods across the topology domains of the host cluster.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"topology_key": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "kubernetes.io/hostname",
+							ValidateFunc: validation.StringIsNotWhiteSpace,
+							Description:  "The node label whose values are the topology domains, e.g. topology.kubernetes.io/zone",
+						},
+						"required": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Description: "If true the pods must be spread, otherwise spreading them is only preferred",
+						},
+					},
+				},
+			},
+			"signing_ca": {
+				Type:        schema.TypeList,
+				MaxItems:    1,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "A certificate authority the certificates of the vcluster are signed with, instead of a generated one. Changing it recreates the vcluster.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"cert": {
+							Type:             schema.TypeString,
+							Required:         true,
+							ForceNew:         true,
+							Sensitive:        true,
+							ValidateDiagFunc: validatePEM("CERTIFICATE"),
+							Description:      "The PEM encoded certificate of the certificate authority",
+						},
+						"key": {
+							Type:             schema.TypeString,
+							Required:         true,
+							ForceNew:         true,
+							Sensitive:        true,
+							ValidateDiagFunc: validatePEM("PRIVATE KEY"),
+							Description:      "The PEM encoded private key of the certificate authority",
+						},
+					},
+				},
+			},
+			"credentials_secret": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: validateResourceName,
+				Description:      "The name of a pre-existing secret in the vcluster namespace holding the credentials vcluster uses instead of generating them",
+			},
+			"probes": {
+				Type:        schema.TypeList,
+				MaxItems:    1,
+				Optional:    true,
+				Description: "Tuning of the control plane probes, e.g. to avoid restart loops on slow storage.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"liveness":  probeSchema("The liveness probe of the control plane"),
+						"readiness": probeSchema("The readiness probe of the control plane"),
+					},
+				},
+			},
+			"metrics": {
+				Type:        schema.TypeList,
+				MaxItems:    1,
+				Optional:    true,
+				Description: "Exposes the control plane metrics for prometheus.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"enabled": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     true,
+							Description: "If true the metrics are exposed",
+						},
+						"service_monitor": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Description: "If true a ServiceMonitor is created for the prometheus operator",
+						},
+						"port": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      8443,
+							ValidateFunc: validation.IsPortNumber,
+							Description:  "The port the metrics are served on",
+						},
+					},
+				},
+			},
+			"values_from_secret": {
+				Type:        schema.TypeList,
+				MaxItems:    1,
+				Optional:    true,
+				Description: "A secret in the host cluster holding helm values, read on every apply. Its values take precedence over the other values and are not recorded in the state.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:             schema.TypeString,
+							Required:         true,
+							ValidateDiagFunc: validateResourceName,
+							Description:      "The name of the secret",
+						},
+						"namespace": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "The namespace of the secret, defaults to the namespace of the vcluster",
+						},
+						"key": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "values.yaml",
+							Description: "The key of the secret holding the values",
+						},
+					},
+				},
+			},
+			"rbac": {
+				Type:        schema.TypeList,
+				MaxItems:    1,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "The permissions of the syncer in the host cluster. Changing it recreates the vcluster, as narrowing the permissions of a running syncer breaks the resources it synced.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"scope": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ForceNew:     true,
+							ValidateFunc: validation.StringInSlice(rbacScopes, false),
+							Description:  "cluster grants the syncer a cluster role, namespace limits it to a role in the namespace of the vcluster",
+						},
+					},
+				},
+			},
+			"registry": {
+				Type:        schema.TypeList,
+				MaxItems:    1,
+				Optional:    true,
+				Description: "The image registry embedded in the vcluster. Only supported by the pro edition.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"enabled": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     true,
+							Description: "If true the embedded registry is enabled",
+						},
+						"persistence": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     true,
+							Description: "If true the images are stored in a persistent volume, otherwise they are lost when the control plane restarts",
+						},
+						"storage_size": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							Default:          "10Gi",
+							ValidateDiagFunc: validateQuantity,
+							Description:      "The size of the persistent volume of the registry, such as 10Gi",
+						},
+					},
+				},
+			},
+			"egress_proxy": {
+				Type:        schema.TypeList,
+				MaxItems:    1,
+				Optional:    true,
+				Description: "The proxy the control plane sends its egress traffic through, set as the proxy environment variables of its containers.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"http_proxy": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validation.IsURLWithScheme([]string{"http", "https"}),
+							Description:  "The proxy of plain http requests",
+						},
+						"https_proxy": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validation.IsURLWithScheme([]string{"http", "https"}),
+							Description:  "The proxy of https requests",
+						},
+						"no_proxy": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "The hosts, domains and CIDRs that are reached without the proxy, such as the service CIDR of the host cluster",
+						},
+					},
+				},
+			},
+			"audit": {
+				Type:        schema.TypeList,
+				MaxItems:    1,
+				Optional:    true,
+				Description: "Audit logging of the requests to the api server of the vcluster.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"enabled": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     true,
+							Description: "If true the requests are audited",
+						},
+						"policy": {
+							Type:             schema.TypeString,
+							Required:         true,
+							ValidateDiagFunc: validateAuditPolicy,
+							Description:      "The audit policy as yaml, a Policy of the audit.k8s.io api group",
+						},
+						"log_path": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "-",
+							ValidateFunc: validation.StringIsNotWhiteSpace,
+							Description:  "The path of the audit log in the control plane container, - logs to stdout",
+						},
+					},
+				},
+			},
+			"cert_renewal": {
+				Type:        schema.TypeList,
+				MaxItems:    1,
+				Optional:    true,
+				Description: "Renews the control plane certificates automatically before they expire.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"enabled": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     true,
+							Description: "If true the certificates are renewed automatically",
+						},
+						"before_expiry": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							Default:          "720h",
+							ValidateDiagFunc: validateDuration,
+							Description:      "How long before their expiry the certificates are renewed, as a duration such as 720h",
+						},
+					},
+				},
+			},
+			"pdb": {
+				Type:        schema.TypeList,
+				MaxItems:    1,
+				Optional:    true,
+				Description: "A PodDisruptionBudget protecting the control plane from voluntary disruptions.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"enabled": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     true,
+							Description: "If true the PodDisruptionBudget is created",
+						},
+						"min_available": {
+							Type:          schema.TypeString,
+							Optional:      true,
+							ConflictsWith: []string{"pdb.0.max_unavailable"},
+							ValidateFunc:  validation.StringMatch(intOrPercentRegexp, "must be a number or a percentage"),
+							Description:   "The number or percentage of control plane pods that must remain available",
+						},
+						"max_unavailable": {
+							Type:          schema.TypeString,
+							Optional:      true,
+							ConflictsWith: []string{"pdb.0.min_available"},
+							ValidateFunc:  validation.StringMatch(intOrPercentRegexp, "must be a number or a percentage"),
+							Description:   "The number or percentage of control plane pods that may be unavailable",
+						},
+					},
+				},
+			},
+			"update_current": {
+				Type:        schema.TypeBool,
+				Description: "If true the kube config of the user running terraform is updated with a context for the vcluster",
+				Optional:    true,
+			},
+			"kube_context_name": {
+				Type:         schema.TypeString,
+				Description:  "The name of the context added to the kube config when update_current is set",
+				Optional:     true,
+				RequiredWith: []string{"update_current"},
+				ValidateFunc: validation.StringMatch(kubeContextNameRegexp, "must be a kube config context name without whitespace"),
+			},
 			"expose": {
 				Type:        schema.TypeBool,
 				Description: "If true will create a load balancer service to expose the vcluster endpoint",
@@ -93,21 +837,297 @@ func resourceVCluster() *schema.Resource {
 				Description: "If true and a local Kubernetes distro is detected, will deploy vcluster with a NodePort service",
 				Optional:    true,
 			},
+			"node_port": {
+				Type:         schema.TypeInt,
+				Description:  "The node port of the service created when expose_local is set",
+				Optional:     true,
+				ValidateFunc: validation.IntBetween(30000, 32767),
+			},
 			"isolate": {
 				Type:        schema.TypeBool,
 				Description: "If true vcluster and its workloads will run in an isolated environment",
 				Optional:    true,
 			},
+			"storage_class": {
+				Type:          schema.TypeString,
+				Description:   "The host cluster storage class to use for the vcluster's data volume. Resolved from storage_class_candidates when those are set",
+				Optional:      true,
+				Computed:      true,
+				ForceNew:      true,
+				ConflictsWith: []string{"storage_class_candidates"},
+			},
+			"storage_class_candidates": {
+				Type:          schema.TypeList,
+				Description:   "A list of preferred storage classes, the first one existing in the host cluster at create time is used",
+				Optional:      true,
+				Elem:          &schema.Schema{Type: schema.TypeString},
+				ConflictsWith: []string{"storage_class"},
+			},
+			"default_storage_class": {
+				Type:             schema.TypeString,
+				Description:      "The storage class persistent volume claims created inside the vcluster default to",
+				Optional:         true,
+				ValidateDiagFunc: validateResourceName,
+			},
 			"context": {
 				Type:        schema.TypeString,
 				Optional:    true,
-				Description: "The kubernetes config context to use",
+				Description: "The kubernetes config context to use. Takes precedence over the config_context of the provider",
+			},
+			"binary_path": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Description:      "The path of the vcluster cli used for this vcluster. Takes precedence over the binary_path of the provider",
+				ValidateDiagFunc: validateExecutable,
+			},
+			"platform_project": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Computed:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: validateResourceName,
+				Description:      "The project of the vcluster platform the vcluster is created in. Only supported by the pro edition",
+			},
+			"auto_delete_after": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ForceNew:         true,
+				ValidateDiagFunc: validateDuration,
+				Description:      "The vcluster deletes itself once it is older than this duration, such as 24h, even when the terraform state is lost. Only supported by the pro edition",
+			},
+			"upgrade_pending": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "True in a plan that upgrades the vcluster in place. It only reflects the plan, applying the upgrade resets it to false",
+			},
+			"upgrade_summary": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The attributes whose changes upgrade the vcluster in a plan. It only reflects the plan, applying the upgrade resets it to empty",
+			},
+			"last_command": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "The vcluster command run by the last create or update, with the values of sensitive flags redacted. The values files it references are removed once it completes, values_applied holds their contents",
+			},
+			"auto_delete_at": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "When the vcluster deletes itself in RFC3339 format, only populated when auto_delete_after is set",
+			},
+			"render_only": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				ForceNew:    true,
+				Description: "If true the manifests of the vcluster are rendered with helm template into rendered_manifests, for review, instead of installing it",
+			},
+			"rendered_manifests": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The manifests rendered by helm template, only populated when render_only is set",
+			},
+			"atomic": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "If true a failed create or upgrade of the helm release is rolled back, instead of leaving a half applied release",
+			},
+			"drain_on_delete": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "If true the nodes of the vcluster are drained before it is deleted, so that its workloads shut down gracefully. The drain takes at most half of the delete timeout, leaving the rest to the delete, and is skipped with a warning when the vcluster is unreachable or times out. Pods without a controller are deleted by force, unless the vcluster syncs the real nodes of the host cluster",
+			},
+			"wait_for_delete": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "If false destroy returns as soon as the deletion of the vcluster is started, instead of waiting for it to be torn down. Resources of the vcluster that fail to be torn down are then orphaned in the host cluster without an error",
+			},
+			"post_renderer": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Description:      "The path of an executable used by helm as a post renderer, to mutate the rendered manifests before they are applied",
+				ValidateDiagFunc: validateExecutable,
 			},
 			"namespace": {
 				Type:        schema.TypeString,
 				Optional:    true,
 				Description: "The kubernetes namespace to use",
 			},
+			"exec_env": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Environment variables of the vcluster, helm and kubectl commands run for this vcluster, and merged into the exec block of the provider's kubernetes block when it is used for this vcluster, e.g. a different AWS_PROFILE. Takes precedence over the environment of the provider and the env of the exec block",
+			},
+			"managed_namespace": {
+				Type:        schema.TypeList,
+				MaxItems:    1,
+				Optional:    true,
+				Description: "If set the namespace is created through the kubernetes block of the provider before the vcluster is installed, and deleted with it. The namespace must not exist yet, and is deleted again when the install fails. Adding or removing the block replaces the vcluster.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"labels": {
+							Type:        schema.TypeMap,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "The labels of the namespace",
+						},
+						"annotations": {
+							Type:        schema.TypeMap,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "The annotations of the namespace",
+						},
+					},
+				},
+			},
+			"force_new_on_namespace_change": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "If true changing the namespace replaces the vcluster instead of attempting an in-place update",
+			},
+			"release_name": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The helm release name of the vcluster, captured when it is created. It is preferred over the vcluster name when deleting",
+			},
+			"skip_read_after_create": {
+				Type:        schema.TypeBool,
+				Description: "If true the vcluster is not read after it is created, saving the commands of a read in large applies. Its computed attributes are then only populated by the next refresh",
+				Optional:    true,
+			},
+			"detect_values_drift": {
+				Type:        schema.TypeBool,
+				Description: "If true the values of the helm release are compared against the values applied by the provider on every read, planning an upgrade when they have been changed out-of-band",
+				Optional:    true,
+			},
+			"values_checksum": {
+				Type:        schema.TypeString,
+				Description: "The checksum of the helm release values as of the last apply. Only populated when detect_values_drift is set",
+				Computed:    true,
+			},
+			"values_drift": {
+				Type:        schema.TypeBool,
+				Description: "True if the helm release values no longer match the values applied by the provider",
+				Computed:    true,
+			},
+			"values_applied": {
+				Type:        schema.TypeString,
+				Description: "The helm values, as yaml, sent to vcluster on the last create or update",
+				Computed:    true,
+				Sensitive:   true,
+			},
+			"exported_config": {
+				Type:        schema.TypeString,
+				Description: "The effective configuration of the vcluster as yaml, including its helm values, for reapplying it elsewhere",
+				Computed:    true,
+				Sensitive:   true,
+			},
+			"kubeconfig": {
+				Type:        schema.TypeString,
+				Description: "The kubeconfig for connecting to the vcluster",
+				Computed:    true,
+				Sensitive:   true,
+			},
+			"active_syncers": {
+				Type:        schema.TypeList,
+				Description: "The resource kinds whose syncer is enabled in the helm release of the vcluster, including the chart defaults",
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"kubeconfig_token_ttl": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ValidateDiagFunc: func(val interface{}, key cty.Path) diag.Diagnostics {
+					if val.(string) == "0" {
+						return nil
+					}
+					return validateDuration(val, key)
+				},
+				Description: "If set the kubeconfig authenticates with a token of kubeconfig_service_account that expires after this duration, such as 24h, instead of a client certificate. 0 requests a token that does not expire. The token is issued when the vcluster is created or this attribute changes, refreshes keep it until it expires",
+			},
+			"kubeconfig_service_account": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "kube-system/terraform",
+				ValidateFunc: validation.StringMatch(regexp.MustCompile(`^[^/]+/[^/]+$`), "must be in the form namespace/name"),
+				Description:  "The service account in the vcluster the kubeconfig token is issued for in the form namespace/name, it is bound to the cluster-admin role. Only used when kubeconfig_token_ttl is set",
+			},
+			"kubeconfig_context": {
+				Type:        schema.TypeString,
+				Description: "The name of the context of the vcluster in the kubeconfig, kube_context_name when it is set",
+				Computed:    true,
+			},
+			"host": {
+				Type:        schema.TypeString,
+				Description: "The server of the vcluster kubeconfig as a url with a scheme and port, such as https://10.0.0.1:443, exactly as the host argument of the kubernetes and helm providers expects it",
+				Computed:    true,
+			},
+			"cert_expiry": {
+				Type:        schema.TypeString,
+				Description: "When the certificate of the vcluster expires in RFC3339 format, that of the certificate authority of the kubeconfig unless served_cert_expiry is set. Empty when the vcluster is unreachable",
+				Computed:    true,
+			},
+			"served_cert_expiry": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "If true cert_expiry is read from the certificate served by the vcluster api server, which is dialed on every refresh. The certificate authority of the kubeconfig is the fallback when the served certificate cannot be read, such as through a background proxy",
+			},
+			"endpoint": {
+				Type:        schema.TypeString,
+				Description: "The address of the vcluster api server as host:port without a scheme, for tools that expect a raw endpoint",
+				Computed:    true,
+			},
+			"token": {
+				Type:        schema.TypeString,
+				Description: "The token of the vcluster kubeconfig, empty when it uses client certificates",
+				Computed:    true,
+				Sensitive:   true,
+			},
+			"cluster_ca_certificate": {
+				Type:        schema.TypeString,
+				Description: "The PEM encoded certificate authority of the vcluster kubeconfig",
+				Computed:    true,
+				Sensitive:   true,
+			},
+			"client_certificate": {
+				Type:        schema.TypeString,
+				Description: "The PEM encoded client certificate of the vcluster kubeconfig, empty when it uses a token",
+				Computed:    true,
+				Sensitive:   true,
+			},
+			"client_key": {
+				Type:        schema.TypeString,
+				Description: "The PEM encoded client key of the vcluster kubeconfig, empty when it uses a token",
+				Computed:    true,
+				Sensitive:   true,
+			},
+			"helm_revision": {
+				Type:        schema.TypeInt,
+				Description: "The revision of the vcluster's helm release",
+				Computed:    true,
+			},
+			"internal_service_dns": {
+				Type:        schema.TypeString,
+				Description: "The in-cluster dns name of the vcluster service, in the form of <name>.<namespace>.svc",
+				Computed:    true,
+			},
+			"control_plane_pod": {
+				Type:        schema.TypeString,
+				Description: "The name of the control plane pod, empty until it is scheduled",
+				Computed:    true,
+			},
+			"control_plane_namespace": {
+				Type:        schema.TypeString,
+				Description: "The namespace of the control plane pod, empty until it is scheduled",
+				Computed:    true,
+			},
 			"status": {
 				Type:     schema.TypeString,
 				Computed: true,
@@ -120,48 +1140,250 @@ func resourceVCluster() *schema.Resource {
 	}
 }
 
-func vclusterBaseArgs(d *schema.ResourceData, args []string) []string {
-	if namespace := d.Get("namespace"); namespace != nil && namespace.(string) != "" {
-		args = append(args, "--namespace", namespace.(string))
-	}
-
+// vclusterContext returns the kubernetes config context the vcluster is managed in. The context of the resource always
+// takes precedence over the config_context of the provider.
+func vclusterContext(d *schema.ResourceData, meta *Meta) string {
 	if context := d.Get("context"); context != nil && context.(string) != "" {
-		args = append(args, "--context", context.(string))
+		if aliased, ok := meta.clusterAliases[context.(string)]; ok {
+			return aliased
+		}
+
+		return context.(string)
 	}
 
-	return args
+	return meta.defaultContext
 }
 
-func resourceVClusterCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	provider := m.(*Meta)
-	_ = provider
-
-	vClusterName := d.Get("name").(string)
-
-	args := vclusterBaseArgs(d, []string{
-		"create",
-		vClusterName,
-		"--connect=false",
-	})
+// validatePEM validates that the value is PEM encoded, with a block whose type ends in the expected type.
+func validatePEM(blockType string) schema.SchemaValidateDiagFunc {
+	return func(val interface{}, key cty.Path) diag.Diagnostics {
+		block, _ := pem.Decode([]byte(val.(string)))
+		if block == nil || !strings.HasSuffix(block.Type, blockType) {
+			return diag.Diagnostics{{
+				Severity:      diag.Error,
+				Summary:       fmt.Sprintf("expected a PEM encoded %s", strings.ToLower(blockType)),
+				AttributePath: key,
+			}}
+		}
 
-	if distro := d.Get("distro"); distro != nil && distro.(string) != "" {
-		args = append(args, fmt.Sprintf("--distro=%s", distro.(string)))
+		return nil
 	}
+}
 
-	if isolate := d.Get("isolate"); isolate != nil {
-		args = append(args, fmt.Sprintf("--isolate=%v", isolate.(bool)))
+// validateDuration validates that the value is a positive duration.
+func validateDuration(val interface{}, key cty.Path) diag.Diagnostics {
+	duration, err := time.ParseDuration(val.(string))
+	if err == nil && duration <= 0 {
+		err = fmt.Errorf("the duration must be positive")
 	}
 
-	if expose := d.Get("expose"); expose != nil {
-		args = append(args, fmt.Sprintf("--expose=%v", expose.(bool)))
+	if err != nil {
+		return diag.Diagnostics{{
+			Severity:      diag.Error,
+			Summary:       fmt.Sprintf("%q is not a valid duration", val.(string)),
+			Detail:        err.Error(),
+			AttributePath: key,
+		}}
 	}
 
-	if exposeLocal := d.Get("expose_local"); exposeLocal != nil {
-		args = append(args, fmt.Sprintf("--expose-local=%v", exposeLocal.(bool)))
+	return nil
+}
+
+// validateAuditPolicy validates that the value is a yaml encoded audit policy.
+func validateAuditPolicy(val interface{}, key cty.Path) diag.Diagnostics {
+	var policy struct {
+		APIVersion string        `json:"apiVersion"`
+		Kind       string        `json:"kind"`
+		Rules      []interface{} `json:"rules"`
 	}
 
-	if disableIngressSync := d.Get("disable_ingress_sync"); disableIngressSync != nil {
-		args = append(args, fmt.Sprintf("--disable-ingress-sync=%v", disableIngressSync.(bool)))
+	err := yaml.Unmarshal([]byte(val.(string)), &policy)
+	if err == nil && (policy.Kind != "Policy" || !strings.HasPrefix(policy.APIVersion, "audit.k8s.io/")) {
+		err = fmt.Errorf("expected kind Policy of the audit.k8s.io api group, got %s %s", policy.APIVersion, policy.Kind)
+	}
+	if err == nil && len(policy.Rules) == 0 {
+		err = fmt.Errorf("the policy has no rules")
+	}
+
+	if err != nil {
+		return diag.Diagnostics{{
+			Severity:      diag.Error,
+			Summary:       "invalid audit policy",
+			Detail:        err.Error(),
+			AttributePath: key,
+		}}
+	}
+
+	return nil
+}
+
+// validateQuantity validates that the value is a positive kubernetes resource quantity.
+func validateQuantity(val interface{}, key cty.Path) diag.Diagnostics {
+	quantity, err := resource.ParseQuantity(val.(string))
+	if err == nil && quantity.Sign() <= 0 {
+		err = fmt.Errorf("the quantity must be positive")
+	}
+
+	if err != nil {
+		return diag.Diagnostics{{
+			Severity:      diag.Error,
+			Summary:       fmt.Sprintf("%q is not a valid quantity", val.(string)),
+			Detail:        err.Error(),
+			AttributePath: key,
+		}}
+	}
+
+	return nil
+}
+
+// validateResourceName validates that the value is a valid kubernetes resource name.
+func validateResourceName(val interface{}, key cty.Path) diag.Diagnostics {
+	if errs := k8svalidation.IsDNS1123Subdomain(val.(string)); len(errs) > 0 {
+		return diag.Diagnostics{{
+			Severity:      diag.Error,
+			Summary:       fmt.Sprintf("%q is not a valid resource name", val.(string)),
+			Detail:        strings.Join(errs, ", "),
+			AttributePath: key,
+		}}
+	}
+
+	return nil
+}
+
+// validateLabelSelector validates that the value is a parsable label selector.
+func validateLabelSelector(val interface{}, key cty.Path) diag.Diagnostics {
+	if _, err := labels.Parse(val.(string)); err != nil {
+		return diag.Diagnostics{{
+			Severity:      diag.Error,
+			Summary:       fmt.Sprintf("%q is not a valid label selector", val.(string)),
+			Detail:        err.Error(),
+			AttributePath: key,
+		}}
+	}
+
+	return nil
+}
+
+// validateAnnotationKeys validates that the keys of the map are valid annotation keys.
+func validateAnnotationKeys(val interface{}, key cty.Path) diag.Diagnostics {
+	var diags diag.Diagnostics
+	for k := range val.(map[string]interface{}) {
+		if errs := k8svalidation.IsQualifiedName(k); len(errs) > 0 {
+			diags = append(diags, diag.Diagnostic{
+				Severity:      diag.Error,
+				Summary:       fmt.Sprintf("%q is not a valid annotation key", k),
+				Detail:        strings.Join(errs, ", "),
+				AttributePath: key.IndexString(k),
+			})
+		}
+	}
+
+	return diags
+}
+
+// validateLabels validates that the map is a valid set of labels.
+func validateLabels(val interface{}, key cty.Path) diag.Diagnostics {
+	var diags diag.Diagnostics
+	for k, v := range val.(map[string]interface{}) {
+		errs := append(k8svalidation.IsQualifiedName(k), k8svalidation.IsValidLabelValue(v.(string))...)
+		if len(errs) > 0 {
+			diags = append(diags, diag.Diagnostic{
+				Severity:      diag.Error,
+				Summary:       fmt.Sprintf("%s=%s is not a valid label", k, v.(string)),
+				Detail:        strings.Join(errs, ", "),
+				AttributePath: key.IndexString(k),
+			})
+		}
+	}
+
+	return diags
+}
+
+// resourceMeta returns the provider meta with the overrides of the resource applied.
+func resourceMeta(d *schema.ResourceData, meta *Meta) *Meta {
+	resource := *meta
+
+	if binaryPath := d.Get("binary_path"); binaryPath != nil && binaryPath.(string) != "" {
+		resource.binaryPath = binaryPath.(string)
+	}
+
+	if env, ok := d.GetOk("exec_env"); ok {
+		resource.env = expandStringMap(env.(map[string]interface{}))
+	}
+
+	return &resource
+}
+
+// probeSchema returns the schema of a block tuning a probe.
+func probeSchema(description string) *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		MaxItems:    1,
+		Optional:    true,
+		Description: description,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"initial_delay_seconds": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					ValidateFunc: validation.IntAtLeast(0),
+					Description:  "The seconds to wait after the container started before probing",
+				},
+				"period_seconds": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					ValidateFunc: validation.IntAtLeast(1),
+					Description:  "The seconds between probes",
+				},
+				"failure_threshold": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					ValidateFunc: validation.IntAtLeast(1),
+					Description:  "The consecutive failures after which the probe fails",
+				},
+			},
+		},
+	}
+}
+
+func vclusterBaseArgs(d *schema.ResourceData, meta *Meta, args []string) []string {
+	if namespace := d.Get("namespace"); namespace != nil && namespace.(string) != "" {
+		args = append(args, "--namespace", namespace.(string))
+	}
+
+	if context := vclusterContext(d, meta); context != "" {
+		args = append(args, "--context", context)
+	}
+
+	return args
+}
+
+// vclusterCreateArgs returns the arguments used to create the vcluster, which are also used to upgrade it in place.
+func vclusterCreateArgs(d *schema.ResourceData, meta *Meta) []string {
+	args := vclusterBaseArgs(d, meta, []string{
+		"create",
+		vclusterName(d),
+		"--connect=false",
+	})
+
+	if distro := vclusterDistro(d); distro != "" {
+		args = append(args, fmt.Sprintf("--distro=%s", distro))
+	}
+
+	if isolate := d.Get("isolate"); isolate != nil {
+		args = append(args, fmt.Sprintf("--isolate=%v", isolate.(bool)))
+	}
+
+	if expose := d.Get("expose"); expose != nil {
+		args = append(args, fmt.Sprintf("--expose=%v", expose.(bool)))
+	}
+
+	if exposeLocal := d.Get("expose_local"); exposeLocal != nil {
+		args = append(args, fmt.Sprintf("--expose-local=%v", exposeLocal.(bool)))
+	}
+
+	if disableIngressSync := d.Get("disable_ingress_sync"); disableIngressSync != nil {
+		args = append(args, fmt.Sprintf("--disable-ingress-sync=%v", disableIngressSync.(bool)))
 	}
 
 	if createNamespace := d.Get("create_namespace"); createNamespace != nil {
@@ -172,33 +1394,207 @@ func resourceVClusterCreate(ctx context.Context, d *schema.ResourceData, m inter
 		args = append(args, fmt.Sprintf("--kubernetes-version=%s", kubernetesVersion.(string)))
 	}
 
-	// extra_values
-	// chart
-	// chart_version
-	// chart_repo
-	// local_chart_dir
+	if setFile := d.Get("set_file").(map[string]interface{}); len(setFile) > 0 {
+		keys := make([]string, 0, len(setFile))
+		for key := range setFile {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			args = append(args, "--set-file", fmt.Sprintf("%s=%s", key, resolvePath(meta, setFile[key].(string))))
+		}
+	}
+
+	if setLiteral := d.Get("set_literal").(map[string]interface{}); len(setLiteral) > 0 {
+		for _, key := range mapKeys(expandStringMap(setLiteral)) {
+			args = append(args, "--set-literal", fmt.Sprintf("%s=%s", key, setLiteral[key].(string)))
+		}
+	}
+
+	if chart := d.Get("chart"); chart != nil && chart.(string) != "" {
+		args = append(args, fmt.Sprintf("--chart-name=%s", chart.(string)))
+	}
+
+	if chartVersion := d.Get("chart_version"); chartVersion != nil && chartVersion.(string) != "" {
+		args = append(args, fmt.Sprintf("--chart-version=%s", chartVersion.(string)))
+	}
+
+	if chartRepo := d.Get("chart_repo"); chartRepo != nil && chartRepo.(string) != "" {
+		args = append(args, fmt.Sprintf("--chart-repo=%s", chartRepo.(string)))
+	}
+
+	if localChartDir := d.Get("local_chart_dir"); localChartDir != nil && localChartDir.(string) != "" {
+		args = append(args, fmt.Sprintf("--local-chart-dir=%s", resolvePath(meta, localChartDir.(string))))
+	}
 
-	cmd := exec.Command(
-		"vcluster",
-		args...,
-	)
+	if project := d.Get("platform_project"); project != nil && project.(string) != "" {
+		args = append(args, fmt.Sprintf("--project=%s", project.(string)))
+	}
+
+	if ttl := d.Get("auto_delete_after"); ttl != nil && ttl.(string) != "" {
+		args = append(args, fmt.Sprintf("--auto-delete-after=%s", ttl.(string)))
+	}
+
+	if d.Get("atomic").(bool) {
+		args = append(args, "--atomic")
+	}
+
+	if postRenderer := d.Get("post_renderer"); postRenderer != nil && postRenderer.(string) != "" {
+		args = append(args, fmt.Sprintf("--post-renderer=%s", executablePath(meta, postRenderer.(string))))
+	}
+
+	return args
+}
 
-	output, err := cmd.CombinedOutput()
+// applyVCluster creates the vcluster, or upgrades it in place, with the helm values rendered from the resource.
+func applyVCluster(ctx context.Context, d *schema.ResourceData, provider *Meta, upgrade bool) diag.Diagnostics {
+	values, err := composeValues(d, provider)
 	if err != nil {
-		return diag.Diagnostics{
-			{
-				Severity: diag.Error,
-				Summary:  fmt.Sprint("vcluster ", strings.Join(args, " ")),
-				Detail:   string(output),
-			},
+		return diag.FromErr(err)
+	}
+
+	valuesFile, err := writeValuesFile(values)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	args := append(vclusterCreateArgs(d, provider), "--extra-values", valuesFile)
+
+	// the values of the secret are passed in a file of their own, which is always removed, so that they are neither
+	// recorded in values_applied nor kept on failure.
+	secretValues, diags := readSecretValues(ctx, d, provider)
+	if diags.HasError() {
+		os.Remove(valuesFile)
+		return diags
+	}
+
+	if secretValues != nil {
+		secretValuesFile, err := writeValuesFile(secretValues)
+		if err != nil {
+			os.Remove(valuesFile)
+			return diag.FromErr(err)
 		}
+		defer os.Remove(secretValuesFile)
+
+		args = append(args, "--extra-values", secretValuesFile)
 	}
 
-	_ = output
+	if upgrade {
+		args = append(args, "--upgrade")
+	}
+
+	d.Set("last_command", redactCommand(vclusterBinary(provider), args))
+
+	_, diags = runVCluster(ctx, provider, args)
+	if diags.HasError() && provider.keepValuesOnFailure {
+		tflog.Warn(ctx, "keeping the values file of the failed command", map[string]interface{}{
+			"path": valuesFile,
+		})
+		return diags
+	}
+
+	os.Remove(valuesFile)
+	if diags.HasError() {
+		return diags
+	}
+
+	d.Set("values_applied", string(values))
+
+	config, err := exportConfig(d, values)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	d.Set("exported_config", config)
+
+	return append(diags, readHelmRevision(ctx, d, provider)...)
+}
+
+func resourceVClusterCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	provider := resourceMeta(d, m.(*Meta))
+
+	vClusterName := d.Get("name").(string)
+
+	if d.Get("render_only").(bool) {
+		// the host cluster is not consulted when only rendering, so the first distro candidate is used.
+		distro := d.Get("distro").(string)
+		if candidates := expandStringSlice(d.Get("distro_candidates").([]interface{})); len(candidates) > 0 {
+			distro = candidates[0]
+		}
+		d.Set("effective_distro", distro)
+
+		diags := renderVCluster(ctx, d, provider)
+		if diags.HasError() {
+			return diags
+		}
+
+		d.SetId(vClusterName)
+		d.Set("release_name", vclusterName(d))
+
+		return diags
+	}
+
+	if candidates := d.Get("storage_class_candidates").([]interface{}); len(candidates) > 0 {
+		storageClass, diags := resolveStorageClass(ctx, d, provider, expandStringSlice(candidates))
+		if diags.HasError() {
+			return diags
+		}
+
+		d.Set("storage_class", storageClass)
+	}
+
+	if candidates := d.Get("distro_candidates").([]interface{}); len(candidates) > 0 {
+		distro, diags := resolveDistro(ctx, d, provider, expandStringSlice(candidates))
+		if diags.HasError() {
+			return diags
+		}
+
+		d.Set("effective_distro", distro)
+	} else {
+		d.Set("effective_distro", d.Get("distro").(string))
+	}
+
+	// the cli only creates the namespace when it does not exist yet, which is recorded so that it is deleted with the
+	// vcluster. A managed namespace is created by the provider instead.
+	createsNamespace := false
+	if _, managed := firstBlock(d, "managed_namespace"); !managed && d.Get("create_namespace").(bool) {
+		exists, diags := namespaceExists(ctx, d, provider)
+		if diags.HasError() {
+			return diags
+		}
+
+		createsNamespace = !exists
+	}
+
+	diags := applyManagedNamespace(ctx, d, provider)
+	if diags.HasError() {
+		return diags
+	}
+
+	diags = applyVCluster(ctx, d, provider, false)
+	if diags.HasError() {
+		// nothing tracks the managed namespace until the id is set, so it is deleted for the next apply to create it
+		// again.
+		return append(diags, deleteManagedNamespace(ctx, d, provider)...)
+	}
+
+	d.Set("namespace_created_by_provider", createsNamespace)
+
 	d.SetId(vClusterName)
 	d.Set("name", vClusterName)
+	d.Set("release_name", vclusterName(d))
 
-	return nil
+	diags = append(diags, updateKubeConfig(ctx, d, provider)...)
+	if diags.HasError() {
+		return diags
+	}
+
+	diags = append(diags, recordValuesChecksum(ctx, d, provider)...)
+	if diags.HasError() || d.Get("skip_read_after_create").(bool) {
+		return diags
+	}
+
+	return append(diags, resourceVClusterRead(ctx, d, m)...)
 }
 
 // ListEntry is a struct matching the results of the vcluster list operation's json output.
@@ -208,82 +1604,444 @@ type ListEntry struct {
 	Status    string
 	Created   time.Time // "2022-12-09T03:12:10Z",
 	Context   string
+	Project   string // only set for vclusters managed by the platform.
 }
 
-func resourceVClusterRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	args := vclusterBaseArgs(d, []string{
+// findVCluster lists the vclusters in the namespace and context of the resource, returning the one with the name.
+func findVCluster(ctx context.Context, d *schema.ResourceData, meta *Meta, name string) (ListEntry, bool, diag.Diagnostics) {
+	args := vclusterBaseArgs(d, meta, []string{
 		"list",
 		"--output", "json",
 	})
 
-	cmd := exec.Command(
-		"vcluster",
-		args...,
-	)
+	return findListEntry(ctx, meta, args, name)
+}
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return diag.Diagnostics{
-			{
-				Severity: diag.Error,
-				Summary:  fmt.Sprint("vcluster ", strings.Join(args, " ")),
-				Detail:   string(output),
-			},
-		}
+// findListEntry runs the list command, returning the entry with the name.
+func findListEntry(ctx context.Context, meta *Meta, args []string, name string) (ListEntry, bool, diag.Diagnostics) {
+	output, diags := runVCluster(ctx, meta, args)
+	if diags.HasError() {
+		return ListEntry{}, false, diags
 	}
 
 	var entries []ListEntry
-	err = json.Unmarshal(output, &entries)
+	err := json.Unmarshal(jsonOutput(output), &entries)
 	if err != nil {
-		return diag.FromErr(err)
+		return ListEntry{}, false, diag.FromErr(err)
 	}
 
-	var resourceEntry ListEntry
 	for _, entry := range entries {
+		if entry.Name == name {
+			return entry, true, nil
+		}
+	}
+
+	return ListEntry{}, false, nil
+}
+
+func resourceVClusterRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	provider := resourceMeta(d, m.(*Meta))
+
+	// nothing is installed in render only mode.
+	if d.Get("render_only").(bool) {
+		return nil
+	}
 
-		if entry.Name == d.Id() {
-			resourceEntry = entry
+	resourceEntry, found, diags := findVCluster(ctx, d, provider, vclusterName(d))
+
+	// the list may not include a vcluster right after it was created, so it is retried before concluding that the
+	// vcluster is gone.
+	for backoff := time.Second; !diags.HasError() && !found && d.IsNewResource() && backoff <= readRetryMaxBackoff; backoff *= 2 {
+		tflog.Debug(ctx, "the vcluster is not listed yet, retrying", map[string]interface{}{
+			"backoff": backoff.String(),
+		})
+
+		select {
+		case <-ctx.Done():
+			return diag.FromErr(ctx.Err())
+		case <-time.After(backoff):
+		}
+
+		resourceEntry, found, diags = findVCluster(ctx, d, provider, vclusterName(d))
+	}
+
+	if diags.HasError() {
+		// listing fails when the namespace was deleted out of band, which means the vcluster is gone with it. It is only
+		// assumed gone when the cluster of the vcluster reports the namespace missing, any other failure is reported.
+		if exists, namespaceDiags := namespaceExists(ctx, d, provider); namespaceDiags.HasError() || exists {
+			return diags
 		}
+
+		tflog.Warn(ctx, "the namespace of the vcluster is gone, removing it from the state", map[string]interface{}{
+			"namespace": vclusterNamespace(d),
+		})
+		d.SetId("")
+		return nil
 	}
 
-	if resourceEntry == (ListEntry{}) {
+	if !found {
 		d.SetId("")
 		return nil
 	}
 
-	d.Set("name", resourceEntry.Name)
+	if clusterName := d.Get("cluster_name"); clusterName == nil || clusterName.(string) == "" {
+		d.Set("name", resourceEntry.Name)
+	}
 	d.Set("status", resourceEntry.Status)
-	d.Set("created", resourceEntry.Created)
-	return nil
+	if resourceEntry.Project != "" {
+		d.Set("platform_project", resourceEntry.Project)
+	}
+	d.Set("created", resourceEntry.Created.Format(time.RFC3339))
+
+	autoDeleteAt := ""
+	if ttl, err := time.ParseDuration(d.Get("auto_delete_after").(string)); err == nil {
+		autoDeleteAt = resourceEntry.Created.Add(ttl).Format(time.RFC3339)
+	}
+	d.Set("auto_delete_at", autoDeleteAt)
+
+	namespace := resourceEntry.Namespace
+	if namespace == "" {
+		namespace = vclusterNamespace(d)
+	}
+	d.Set("internal_service_dns", fmt.Sprintf("%s.%s.svc", vclusterReleaseName(d), namespace))
+
+	diags = reconcileIsolate(ctx, d, provider, namespace)
+	diags = append(diags, reconcileCreatedNamespace(ctx, d, provider)...)
+	diags = append(diags, readHelmRevision(ctx, d, provider)...)
+	diags = append(diags, readActiveSyncers(ctx, d, provider)...)
+	if !keepsIssuedToken(d) {
+		diags = append(diags, readConnectionDetails(ctx, d, provider)...)
+	}
+	diags = append(diags, readControlPlanePod(ctx, d, provider, namespace)...)
+
+	return append(diags, detectValuesDrift(ctx, d, provider)...)
 }
 
 func resourceVClusterUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	return nil
+	provider := resourceMeta(d, m.(*Meta))
+
+	// the upgrade is no longer pending once it is applied.
+	d.Set("upgrade_pending", false)
+	d.Set("upgrade_summary", "")
+
+	if d.Get("render_only").(bool) {
+		return renderVCluster(ctx, d, provider)
+	}
+
+	if d.HasChange("managed_namespace") {
+		diags := applyManagedNamespace(ctx, d, provider)
+		if diags.HasError() {
+			return diags
+		}
+	}
+
+	var diags diag.Diagnostics
+	if d.HasChanges(upgradeAttributes...) {
+		diags = applyVCluster(ctx, d, provider, true)
+		if diags.HasError() {
+			return diags
+		}
+	}
+
+	if d.HasChanges("update_current", "kube_context_name") {
+		diags = append(diags, updateKubeConfig(ctx, d, provider)...)
+		if diags.HasError() {
+			return diags
+		}
+	}
+
+	if d.HasChange("restart_generation") {
+		diags = append(diags, restartControlPlane(ctx, d, provider)...)
+		if diags.HasError() {
+			return diags
+		}
+	}
+
+	// refreshes keep the issued token, so a new one is only issued when the way it is issued changes.
+	if d.HasChanges("kubeconfig_token_ttl", "kubeconfig_service_account") {
+		diags = append(diags, readConnectionDetails(ctx, d, provider)...)
+	}
+
+	return append(diags, recordValuesChecksum(ctx, d, provider)...)
+}
+
+func resourceVClusterCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	// the files are resolved against the working directory of the provider, so they can only be checked here. The paths
+	// are unknown when they are interpolated from resources that do not exist yet.
+	if d.NewValueKnown("set_file") {
+		for key, path := range d.Get("set_file").(map[string]interface{}) {
+			if !d.NewValueKnown("set_file." + key) {
+				continue
+			}
+
+			if _, err := os.Stat(resolvePath(m.(*Meta), path.(string))); err != nil {
+				return fmt.Errorf("set_file %q: %w", key, err)
+			}
+		}
+	}
+
+	if d.Get("edition").(string) == "oss" {
+		for _, key := range proOnlyAttributes {
+			if _, ok := d.GetOk(key); ok {
+				return fmt.Errorf("%s is only supported by the pro edition of vcluster", key)
+			}
+		}
+	}
+
+	volumes := map[string]bool{}
+	for i, v := range d.Get("extra_volumes").([]interface{}) {
+		volume := v.(map[string]interface{})
+		volumes[volume["name"].(string)] = true
+
+		// the sources are unknown when they are interpolated from resources that do not exist yet.
+		key := fmt.Sprintf("extra_volumes.%d", i)
+		if !d.NewValueKnown(key+".config_map") || !d.NewValueKnown(key+".secret") || !d.NewValueKnown(key+".empty_dir") {
+			continue
+		}
+
+		sources := 0
+		for _, set := range []bool{volume["config_map"].(string) != "", volume["secret"].(string) != "", volume["empty_dir"].(bool)} {
+			if set {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return fmt.Errorf("%s: exactly one of config_map, secret or empty_dir must be set", key)
+		}
+	}
+
+	for i, v := range d.Get("extra_volume_mounts").([]interface{}) {
+		// the name is unknown when it is interpolated from a resource that does not exist yet.
+		if name := v.(map[string]interface{})["name"].(string); name != "" && !volumes[name] {
+			return fmt.Errorf("extra_volume_mounts.%d: volume %q is not in extra_volumes", i, name)
+		}
+	}
+
+	if err := checkContextAlias(d, m.(*Meta)); err != nil {
+		return err
+	}
+
+	if _, ok := d.GetOk("node_port"); ok && !d.Get("expose_local").(bool) {
+		return fmt.Errorf("node_port can only be set when expose_local is true")
+	}
+
+	// edits to a local chart are not visible in the configuration, so its checksum is planned to upgrade the vcluster
+	// when they are made.
+	checksum := ""
+	if localChartDir := d.Get("local_chart_dir").(string); localChartDir != "" {
+		var err error
+		checksum, err = localChartChecksum(resolvePath(m.(*Meta), localChartDir))
+		if err != nil {
+			return fmt.Errorf("local_chart_dir: %w", err)
+		}
+	}
+
+	if checksum != d.Get("local_chart_checksum").(string) {
+		if err := d.SetNew("local_chart_checksum", checksum); err != nil {
+			return err
+		}
+	}
+
+	if d.Id() == "" || d.HasChanges("distro", "chart", "chart_version", "chart_repo") {
+		if err := checkChartVersion(ctx, d); err != nil {
+			return err
+		}
+	}
+
+	if d.Id() == "" {
+		return nil
+	}
+
+	// a vcluster cannot be moved between namespaces in place.
+	if d.HasChange("namespace") && d.Get("force_new_on_namespace_change").(bool) {
+		if err := d.ForceNew("namespace"); err != nil {
+			return err
+		}
+	}
+
+	// the namespace is only managed when it is created with the vcluster, and deleted with it, so adding or removing the
+	// block replaces the vcluster.
+	if d.HasChange("managed_namespace") {
+		old, new := d.GetChange("managed_namespace")
+		if (len(old.([]interface{})) == 0) != (len(new.([]interface{})) == 0) {
+			if err := d.ForceNew("managed_namespace"); err != nil {
+				return err
+			}
+		}
+	}
+
+	// plan an upgrade when drift was detected in the helm release values, so that applying restores the values
+	// managed by the provider.
+	if d.Get("detect_values_drift").(bool) && d.Get("values_drift").(bool) {
+		if err := d.SetNew("values_drift", false); err != nil {
+			return err
+		}
+	}
+
+	return planUpgrade(d)
+}
+
+// planUpgrade reports whether applying the plan upgrades the vcluster in place, and which of the upgradeAttributes
+// cause it. A namespace change replaces the vcluster unless force_new_on_namespace_change is disabled, so it is not
+// an upgrade then.
+func planUpgrade(d *schema.ResourceDiff) error {
+	changed := []string{}
+	for _, key := range upgradeAttributes {
+		if key == "namespace" && d.Get("force_new_on_namespace_change").(bool) {
+			continue
+		}
+
+		if d.HasChange(key) {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+
+	if len(changed) == 0 {
+		return nil
+	}
+
+	if err := d.SetNew("upgrade_pending", true); err != nil {
+		return err
+	}
+
+	return d.SetNew("upgrade_summary", fmt.Sprintf("upgrades the vcluster because of changes to %s", strings.Join(changed, ", ")))
+}
+
+// vclusterName returns the name of the vcluster in the host cluster, which is its name unless cluster_name is set.
+func vclusterName(d *schema.ResourceData) string {
+	if clusterName := d.Get("cluster_name"); clusterName != nil && clusterName.(string) != "" {
+		return clusterName.(string)
+	}
+
+	if name := d.Get("name"); name != nil && name.(string) != "" {
+		return name.(string)
+	}
+
+	// the name is not known yet while importing.
+	return d.Id()
+}
+
+// vclusterDistro returns the distro of the vcluster, which is the one chosen from distro_candidates when distro is not
+// set.
+func vclusterDistro(d *schema.ResourceData) string {
+	if distro := d.Get("distro"); distro != nil && distro.(string) != "" {
+		return distro.(string)
+	}
+
+	if distro := d.Get("effective_distro"); distro != nil {
+		return distro.(string)
+	}
+
+	return ""
+}
+
+// vclusterNamespace returns the namespace of the vcluster, which the cli derives from its name when none is configured.
+func vclusterNamespace(d *schema.ResourceData) string {
+	if namespace := d.Get("namespace"); namespace != nil && namespace.(string) != "" {
+		return namespace.(string)
+	}
+
+	return "vcluster-" + vclusterName(d)
+}
+
+// vclusterReleaseName returns the helm release name of the vcluster, falling back to its name when the release name
+// has not been captured yet.
+func vclusterReleaseName(d *schema.ResourceData) string {
+	if releaseName := d.Get("release_name"); releaseName != nil && releaseName.(string) != "" {
+		return releaseName.(string)
+	}
+
+	return vclusterName(d)
 }
 
 func resourceVClusterDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	args := vclusterBaseArgs(d, []string{
+	provider := resourceMeta(d, m.(*Meta))
+
+	if d.Get("render_only").(bool) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.Timeout(schema.TimeoutDelete))
+	defer cancel()
+
+	var drainDiags diag.Diagnostics
+	if d.Get("drain_on_delete").(bool) {
+		drainDiags = drainVCluster(ctx, d, provider)
+	}
+
+	args := vclusterBaseArgs(d, provider, []string{
 		"delete",
-		d.Get("name").(string),
+		vclusterReleaseName(d),
 	})
 
-	cmd := exec.Command(
-		"vcluster",
-		args...,
-	)
+	if !d.Get("wait_for_delete").(bool) {
+		args = append(args, "--wait=false")
+	}
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
+	if d.Get("namespace_created_by_provider").(bool) {
+		args = append(args, "--delete-namespace")
+	}
+
+	progress := watchDelete(ctx, provider, vclusterBaseArgs(d, provider, []string{"list", "--output", "json"}), vclusterName(d))
+	output, diags := runVCluster(ctx, provider, args)
+	lastStatus := progress()
+
+	if ctx.Err() == context.DeadlineExceeded {
 		return diag.Diagnostics{
 			{
 				Severity: diag.Error,
-				Summary:  fmt.Sprint("vcluster ", strings.Join(args, " ")),
-				Detail:   string(output),
+				Summary:  "timed out deleting the vcluster",
+				Detail:   fmt.Sprintf("vcluster %s was still %s after %s", vclusterName(d), lastStatus, d.Timeout(schema.TimeoutDelete)),
 			},
 		}
 	}
 
+	if diags.HasError() {
+		return diags
+	}
+
 	_ = output
 
-	return nil
+	return append(drainDiags, deleteManagedNamespace(ctx, d, provider)...)
+}
+
+// watchDelete periodically logs the status of the vcluster while it is being deleted. The returned function stops
+// watching and returns the last observed status.
+func watchDelete(ctx context.Context, meta *Meta, listArgs []string, name string) func() string {
+	done := make(chan struct{})
+	statuses := make(chan string)
+
+	go func() {
+		status := "terminating"
+		defer func() { statuses <- status }()
+
+		ticker := time.NewTicker(deleteProgressInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				entry, found, diags := findListEntry(ctx, meta, listArgs, name)
+				if diags.HasError() || !found {
+					continue
+				}
+
+				status = entry.Status
+				tflog.Info(ctx, "waiting for the vcluster to be deleted", map[string]interface{}{
+					"name":   name,
+					"status": status,
+				})
+			}
+		}
+	}()
+
+	return func() string {
+		close(done)
+		return <-statuses
+	}
 }
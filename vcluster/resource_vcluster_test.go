@@ -0,0 +1,941 @@
+package vcluster
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// unknownValue is the value of configuration that is unknown until apply.
+const unknownValue = "74D93920-ED26-11E3-AC10-0800200C9A66"
+
+// testVClusterPlan plans changing the configuration of an existing vcluster from old to new, returning the planned
+// diff and the data an update is called with.
+func testVClusterPlan(t *testing.T, meta *Meta, old, new map[string]interface{}) (*terraform.InstanceDiff, *schema.ResourceData) {
+	t.Helper()
+
+	r := resourceVCluster()
+	current := testVCluster(t, old)
+	current.SetId("test")
+	state := current.State()
+
+	diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(new), meta)
+	if err != nil {
+		t.Fatalf("planning: %v", err)
+	}
+
+	d, err := schema.InternalMap(r.Schema).Data(state, diff)
+	if err != nil {
+		t.Fatalf("planning: %v", err)
+	}
+
+	return diff, d
+}
+
+// testValidate validates the raw configuration of a vcluster resource against its schema.
+func testValidate(raw map[string]interface{}) diag.Diagnostics {
+	return resourceVCluster().Validate(terraform.NewResourceConfigRaw(raw))
+}
+
+func TestResourceVClusterDeleteTargetsReleaseName(t *testing.T) {
+	meta, runner := testMeta(t, map[string]interface{}{})
+
+	d := testVCluster(t, map[string]interface{}{
+		"name":            "test",
+		"wait_for_delete": true,
+	})
+	d.SetId("test")
+	d.Set("release_name", "custom-release")
+
+	if diags := resourceVClusterDelete(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	runner.find(t, "vcluster delete custom-release")
+}
+
+func TestResourceVClusterDeleteWaitForDelete(t *testing.T) {
+	for _, wait := range []bool{true, false} {
+		meta, runner := testMeta(t, map[string]interface{}{})
+
+		d := testVCluster(t, map[string]interface{}{
+			"name":            "test",
+			"wait_for_delete": wait,
+		})
+		d.SetId("test")
+
+		if diags := resourceVClusterDelete(context.Background(), d, meta); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		if args := runner.find(t, "vcluster delete test").Args; hasArg(args, "--wait=false") == wait {
+			t.Fatalf("wait_for_delete = %t: expected --wait=false to be passed to be %t, got %q", wait, !wait, args)
+		}
+	}
+}
+
+func TestResourceVClusterUpdateUpgradesOnlyOnReleaseChanges(t *testing.T) {
+	cases := []struct {
+		new      map[string]interface{}
+		upgrades bool
+	}{
+		{new: map[string]interface{}{"name": "test", "wait_for_delete": false}, upgrades: false},
+		{new: map[string]interface{}{"name": "test", "drain_on_delete": true}, upgrades: false},
+		{new: map[string]interface{}{"name": "test", "skip_read_after_create": true}, upgrades: false},
+		{new: map[string]interface{}{"name": "test", "syncer_log_level": 4}, upgrades: true},
+	}
+
+	for _, c := range cases {
+		meta, runner := testMeta(t, map[string]interface{}{})
+		_, d := testVClusterPlan(t, meta, map[string]interface{}{"name": "test"}, c.new)
+
+		if diags := resourceVClusterUpdate(context.Background(), d, meta); diags.HasError() {
+			t.Fatalf("updating to %v: unexpected diagnostics %v", c.new, diags)
+		}
+
+		if runner.ran("vcluster create ") != c.upgrades {
+			t.Fatalf("updating to %v: expected upgrade %t, ran %q", c.new, c.upgrades, runner.lines())
+		}
+	}
+}
+
+func TestResourceVClusterPlanUpgrade(t *testing.T) {
+	cases := []struct {
+		old     map[string]interface{}
+		new     map[string]interface{}
+		summary string
+	}{
+		{
+			old: map[string]interface{}{"name": "test"},
+			new: map[string]interface{}{"name": "test", "wait_for_delete": false, "drain_on_delete": true},
+		},
+		{
+			old:     map[string]interface{}{"name": "test"},
+			new:     map[string]interface{}{"name": "test", "syncer_log_level": 4, "priority_class_name": "critical", "wait_for_delete": false},
+			summary: "upgrades the vcluster because of changes to priority_class_name, syncer_log_level",
+		},
+		{
+			// the namespace change replaces the vcluster.
+			old: map[string]interface{}{"name": "test", "namespace": "one"},
+			new: map[string]interface{}{"name": "test", "namespace": "two"},
+		},
+		{
+			old:     map[string]interface{}{"name": "test", "namespace": "one", "force_new_on_namespace_change": false},
+			new:     map[string]interface{}{"name": "test", "namespace": "two", "force_new_on_namespace_change": false},
+			summary: "upgrades the vcluster because of changes to namespace",
+		},
+	}
+
+	for _, c := range cases {
+		meta, _ := testMeta(t, map[string]interface{}{})
+		_, d := testVClusterPlan(t, meta, c.old, c.new)
+
+		if pending := d.Get("upgrade_pending").(bool); pending != (c.summary != "") {
+			t.Errorf("planning %v: expected upgrade_pending to be %t", c.new, c.summary != "")
+		}
+		if summary := d.Get("upgrade_summary").(string); summary != c.summary {
+			t.Errorf("planning %v: expected the summary %q, got %q", c.new, c.summary, summary)
+		}
+	}
+}
+
+func TestResourceVClusterNamespaceChangeReplaces(t *testing.T) {
+	meta, _ := testMeta(t, map[string]interface{}{})
+
+	for _, forceNew := range []bool{true, false} {
+		old := map[string]interface{}{"name": "test", "namespace": "one", "force_new_on_namespace_change": forceNew}
+		new := map[string]interface{}{"name": "test", "namespace": "two", "force_new_on_namespace_change": forceNew}
+
+		diff, _ := testVClusterPlan(t, meta, old, new)
+		if diff.RequiresNew() != forceNew {
+			t.Fatalf("force_new_on_namespace_change = %t: expected replacement %t", forceNew, forceNew)
+		}
+	}
+}
+
+func TestVClusterBaseArgsContext(t *testing.T) {
+	meta, _ := testMeta(t, map[string]interface{}{
+		"kubernetes": []interface{}{map[string]interface{}{"config_context": "provider"}},
+	})
+
+	cases := []struct {
+		context  string
+		expected string
+	}{
+		{context: "", expected: "provider"},
+		{context: "resource", expected: "resource"},
+	}
+
+	for _, c := range cases {
+		d := testVCluster(t, map[string]interface{}{"name": "test", "context": c.context})
+
+		args := vclusterBaseArgs(d, meta, []string{"list"})
+		if expected := []string{"list", "--context", c.expected}; !reflect.DeepEqual(args, expected) {
+			t.Fatalf("context %q: expected %q, got %q", c.context, expected, args)
+		}
+	}
+}
+
+func TestResourceVClusterDeleteTimeout(t *testing.T) {
+	interval := deleteProgressInterval
+	deleteProgressInterval = 10 * time.Millisecond
+	t.Cleanup(func() { deleteProgressInterval = interval })
+
+	meta, runner := testMeta(t, map[string]interface{}{})
+	runner.on("vcluster list", fakeResult{
+		stdout: `[{"Name": "test", "Status": "Terminating", "Created": "2022-12-09T03:12:10Z"}]`,
+	})
+	meta.runner = func(cmd *exec.Cmd) error {
+		if cmd.Args[1] == "delete" {
+			// the delete outlasts the timeout.
+			time.Sleep(200 * time.Millisecond)
+			return nil
+		}
+		return runner.run(cmd)
+	}
+
+	current := testVCluster(t, map[string]interface{}{"name": "test"})
+	current.SetId("test")
+
+	r := resourceVCluster()
+	timeout := 50 * time.Millisecond
+	r.Timeouts = &schema.ResourceTimeout{Delete: &timeout}
+	d := r.Data(current.State())
+
+	var logs bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &logs)
+
+	diags := resourceVClusterDelete(ctx, d, meta)
+	if !diags.HasError() || diags[0].Summary != "timed out deleting the vcluster" {
+		t.Fatalf("expected the delete to time out, got %v", diags)
+	}
+	if !strings.Contains(diags[0].Detail, "was still Terminating after 50ms") {
+		t.Fatalf("expected the last status and the timeout to be reported, got %q", diags[0].Detail)
+	}
+
+	entries, err := tflogtest.MultilineJSONDecode(&logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logged := false
+	for _, entry := range entries {
+		if entry["@message"] == "waiting for the vcluster to be deleted" && entry["status"] == "Terminating" {
+			logged = true
+		}
+	}
+	if !logged {
+		t.Fatalf("expected the progress of the delete to be logged, got %v", entries)
+	}
+}
+
+func TestResourceVClusterProOnlyAttributes(t *testing.T) {
+	attributes := map[string]interface{}{
+		"webhooks":          []interface{}{map[string]interface{}{"validating": "sync"}},
+		"auto_delete_after": "24h",
+		"platform_project":  "team",
+		"registry":          []interface{}{map[string]interface{}{"enabled": true}},
+	}
+
+	for key, value := range attributes {
+		for _, edition := range []string{"oss", "pro"} {
+			meta, _ := testMeta(t, map[string]interface{}{})
+
+			_, err := resourceVCluster().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(map[string]interface{}{
+				"name":    "test",
+				"edition": edition,
+				key:       value,
+			}), meta)
+			if rejected := err != nil; rejected != (edition == "oss") {
+				t.Errorf("planning %s for the %s edition: unexpected error %v", key, edition, err)
+			}
+			if expected := key + " is only supported by the pro edition of vcluster"; err != nil && err.Error() != expected {
+				t.Errorf("planning %s for the %s edition: expected the error %q, got %v", key, edition, expected, err)
+			}
+		}
+	}
+}
+
+func TestResourceMetaBinaryPath(t *testing.T) {
+	meta, runner := testMeta(t, map[string]interface{}{})
+
+	overridden := testVCluster(t, map[string]interface{}{"name": "one", "binary_path": "/opt/vcluster/bin/vcluster"})
+	other := testVCluster(t, map[string]interface{}{"name": "two"})
+
+	for _, d := range []*schema.ResourceData{overridden, other} {
+		if _, diags := runVCluster(context.Background(), resourceMeta(d, meta), []string{"list"}); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+	}
+
+	expected := []string{"/opt/vcluster/bin/vcluster list", "vcluster list"}
+	if lines := runner.lines(); !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("expected %q, ran %q", expected, lines)
+	}
+
+	if meta.binaryPath != "vcluster" {
+		t.Fatalf("expected the provider binary path to be unchanged, got %q", meta.binaryPath)
+	}
+}
+
+func TestResourceMetaExecEnv(t *testing.T) {
+	meta, runner := testMeta(t, map[string]interface{}{})
+	runner.on("vcluster list", fakeResult{
+		stdout: `[{"Name": "test", "Status": "Running", "Created": "2022-12-09T03:12:10Z"}]`,
+	})
+	runner.on("kubectl get pods", fakeResult{stdout: `{"items": []}`})
+
+	d := testVCluster(t, map[string]interface{}{
+		"name":     "test",
+		"exec_env": map[string]interface{}{"AWS_PROFILE": "staging"},
+	})
+	d.SetId("test")
+
+	if diags := resourceVClusterRead(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	for _, prefix := range []string{"vcluster list", "kubectl get pods"} {
+		if !hasEnv(runner.find(t, prefix).Env, "AWS_PROFILE=staging") {
+			t.Fatalf("expected the exec_env of the resource in the environment of %s", prefix)
+		}
+	}
+
+	if _, diags := runCommand(context.Background(), meta, "vcluster", []string{"version"}); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if hasEnv(runner.find(t, "vcluster version").Env, "AWS_PROFILE=staging") {
+		t.Fatal("expected the exec_env of the resource to stay out of the commands of the provider")
+	}
+}
+
+func TestResourceVClusterReadServiceDNS(t *testing.T) {
+	cases := []struct {
+		config   map[string]interface{}
+		listed   string
+		expected string
+	}{
+		{
+			config:   map[string]interface{}{"name": "test", "namespace": "team"},
+			listed:   `[{"Name": "test", "Namespace": "team", "Status": "Running", "Created": "2022-12-09T03:12:10Z"}]`,
+			expected: "test.team.svc",
+		},
+		{
+			config:   map[string]interface{}{"name": "test"},
+			listed:   `[{"Name": "test", "Status": "Running", "Created": "2022-12-09T03:12:10Z"}]`,
+			expected: "test.vcluster-test.svc",
+		},
+	}
+
+	for _, c := range cases {
+		meta, runner := testMeta(t, map[string]interface{}{})
+		runner.on("vcluster list", fakeResult{stdout: c.listed})
+		runner.on("kubectl get pods", fakeResult{stdout: `{"items": []}`})
+
+		d := testVCluster(t, c.config)
+		d.SetId("test")
+
+		if diags := resourceVClusterRead(context.Background(), d, meta); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		if dns := d.Get("internal_service_dns").(string); dns != c.expected {
+			t.Fatalf("expected %q, got %q", c.expected, dns)
+		}
+	}
+}
+
+func TestResourceVClusterReadNamespaceGone(t *testing.T) {
+	cases := []struct {
+		name      string
+		namespace fakeResult
+		removed   bool
+	}{
+		{
+			name:    "namespace gone",
+			removed: true,
+		},
+		{
+			name:      "namespace exists",
+			namespace: fakeResult{stdout: "namespace/team\n"},
+		},
+		{
+			name:      "namespace lookup fails",
+			namespace: fakeResult{stdout: "Unable to connect to the server: i/o timeout\n", err: exitError(1)},
+		},
+	}
+
+	for _, c := range cases {
+		meta, runner := testMeta(t, map[string]interface{}{})
+		runner.on("vcluster list", fakeResult{stdout: "fatal   namespaces \"team\" not found\n", err: exitError(1)})
+		runner.on("kubectl get namespace team", c.namespace)
+
+		d := testVCluster(t, map[string]interface{}{"name": "test", "namespace": "team", "context": "kind-host"})
+		d.SetId("test")
+
+		diags := resourceVClusterRead(context.Background(), d, meta)
+		if removed := d.Id() == ""; removed != c.removed {
+			t.Fatalf("%s: expected the vcluster to be removed from the state to be %t", c.name, c.removed)
+		}
+		if diags.HasError() == c.removed {
+			t.Fatalf("%s: unexpected diagnostics %v", c.name, diags)
+		}
+
+		runner.find(t, "kubectl get namespace team --ignore-not-found --output name --context kind-host")
+	}
+}
+
+func TestResourceVClusterReadCreated(t *testing.T) {
+	meta, runner := testMeta(t, map[string]interface{}{})
+	runner.on("vcluster list", fakeResult{
+		stdout: `[{"Name": "test", "Status": "Running", "Created": "2022-12-09T05:12:10.123+02:00"}]`,
+	})
+	runner.on("kubectl get pods", fakeResult{stdout: `{"items": []}`})
+
+	d := testVCluster(t, map[string]interface{}{"name": "test", "auto_delete_after": "1h"})
+	d.SetId("test")
+
+	if diags := resourceVClusterRead(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	if created := d.Get("created").(string); created != "2022-12-09T05:12:10+02:00" {
+		t.Fatalf("expected created to be stored as RFC3339, got %q", created)
+	}
+	if autoDeleteAt := d.Get("auto_delete_at").(string); autoDeleteAt != "2022-12-09T06:12:10+02:00" {
+		t.Fatalf("expected auto_delete_at to be stored as RFC3339, got %q", autoDeleteAt)
+	}
+}
+
+func TestResourceVClusterReadRetriesNewResource(t *testing.T) {
+	for _, isNew := range []bool{true, false} {
+		meta, runner := testMeta(t, map[string]interface{}{})
+		runner.on("kubectl get pods", fakeResult{stdout: `{"items": []}`})
+
+		lists := 0
+		meta.runner = func(cmd *exec.Cmd) error {
+			if strings.HasPrefix(strings.Join(cmd.Args, " "), "vcluster list") {
+				lists++
+				// the list only includes the vcluster the second time.
+				if lists > 1 {
+					io.WriteString(cmd.Stdout, `[{"Name": "test", "Status": "Running", "Created": "2022-12-09T03:12:10Z"}]`)
+				} else {
+					io.WriteString(cmd.Stdout, `[]`)
+				}
+			}
+			return runner.run(cmd)
+		}
+
+		d := testVCluster(t, map[string]interface{}{"name": "test"})
+		d.SetId("test")
+		if isNew {
+			d.MarkNewResource()
+		}
+
+		if diags := resourceVClusterRead(context.Background(), d, meta); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		if isNew {
+			if lists != 2 || d.Id() != "test" || d.Get("status").(string) != "Running" {
+				t.Fatalf("expected the new vcluster to be found on the second list, listed %d times and got the id %q", lists, d.Id())
+			}
+			continue
+		}
+
+		// an existing vcluster that is not listed is gone.
+		if lists != 1 || d.Id() != "" {
+			t.Fatalf("expected the missing vcluster to be removed after one list, listed %d times and got the id %q", lists, d.Id())
+		}
+	}
+}
+
+func TestVClusterCreateArgsPostRenderer(t *testing.T) {
+	meta, _ := testMeta(t, map[string]interface{}{"working_dir": "/work"})
+
+	cases := []struct {
+		postRenderer string
+		expected     string
+	}{
+		{postRenderer: "./hooks/kustomize.sh", expected: "--post-renderer=/work/hooks/kustomize.sh"},
+		{postRenderer: "kustomize-renderer", expected: "--post-renderer=kustomize-renderer"},
+	}
+
+	for _, c := range cases {
+		d := testVCluster(t, map[string]interface{}{"name": "test", "post_renderer": c.postRenderer})
+		if args := vclusterCreateArgs(d, meta); !hasArg(args, c.expected) {
+			t.Fatalf("expected %s in %q", c.expected, args)
+		}
+	}
+
+	d := testVCluster(t, map[string]interface{}{"name": "test"})
+	for _, arg := range vclusterCreateArgs(d, meta) {
+		if strings.HasPrefix(arg, "--post-renderer") {
+			t.Fatalf("expected no post renderer, got %s", arg)
+		}
+	}
+}
+
+func TestVClusterCreateArgsAtomic(t *testing.T) {
+	meta, _ := testMeta(t, map[string]interface{}{})
+
+	for _, atomic := range []bool{true, false} {
+		d := testVCluster(t, map[string]interface{}{"name": "test", "atomic": atomic})
+		if args := vclusterCreateArgs(d, meta); hasArg(args, "--atomic") != atomic {
+			t.Fatalf("atomic = %t: expected --atomic in %q to be %t", atomic, args, atomic)
+		}
+	}
+}
+
+func TestVClusterCreateArgsKubernetesVersion(t *testing.T) {
+	meta, _ := testMeta(t, map[string]interface{}{})
+
+	cases := []struct {
+		distro            string
+		kubernetesVersion string
+		emitted           bool
+	}{
+		{distro: "", kubernetesVersion: "v1.25", emitted: true},
+		{distro: "k3s", kubernetesVersion: "v1.25", emitted: true},
+		{distro: "k0s", kubernetesVersion: "v1.25", emitted: true},
+		{distro: "K8S", kubernetesVersion: "v1.25", emitted: true},
+		{distro: "k8s", kubernetesVersion: ""},
+	}
+
+	for _, c := range cases {
+		d := testVCluster(t, map[string]interface{}{
+			"name":               "test",
+			"distro":             c.distro,
+			"kubernetes_version": c.kubernetesVersion,
+		})
+
+		args := vclusterCreateArgs(d, meta)
+		if emitted := hasArg(args, "--kubernetes-version="+c.kubernetesVersion); emitted != c.emitted {
+			t.Errorf("distro %q: expected --kubernetes-version to be emitted to be %t, got %q", c.distro, c.emitted, args)
+		}
+		if !c.emitted {
+			for _, arg := range args {
+				if strings.HasPrefix(arg, "--kubernetes-version") {
+					t.Errorf("distro %q: expected no kubernetes version, got %s", c.distro, arg)
+				}
+			}
+		}
+	}
+}
+
+func TestVClusterCreateArgsSetFile(t *testing.T) {
+	meta, _ := testMeta(t, map[string]interface{}{"working_dir": "/work"})
+
+	d := testVCluster(t, map[string]interface{}{
+		"name": "test",
+		"set_file": map[string]interface{}{
+			"syncer.config": "syncer.yaml",
+			"certs.ca":      "/etc/ca.crt",
+		},
+		"set_literal": map[string]interface{}{
+			"vcluster.image": "rancher/k3s:v1.25.5-k3s1",
+		},
+	})
+
+	args := strings.Join(vclusterCreateArgs(d, meta), " ")
+	expected := "--set-file certs.ca=/etc/ca.crt --set-file syncer.config=/work/syncer.yaml --set-literal vcluster.image=rancher/k3s:v1.25.5-k3s1"
+	if !strings.Contains(args, expected) {
+		t.Fatalf("expected the args to contain %q, got %q", expected, args)
+	}
+}
+
+func TestResourceVClusterPlanSetFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "syncer.yaml"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	meta, _ := testMeta(t, map[string]interface{}{"working_dir": dir})
+	r := resourceVCluster()
+
+	cases := []struct {
+		path  string
+		fails bool
+	}{
+		{path: "syncer.yaml", fails: false},
+		{path: "missing.yaml", fails: true},
+		// unknown until apply.
+		{path: unknownValue, fails: false},
+	}
+
+	for _, c := range cases {
+		current := testVCluster(t, map[string]interface{}{"name": "test"})
+		current.SetId("test")
+
+		_, err := r.Diff(context.Background(), current.State(), terraform.NewResourceConfigRaw(map[string]interface{}{
+			"name":     "test",
+			"set_file": map[string]interface{}{"syncer.config": c.path},
+		}), meta)
+		if (err != nil) != c.fails {
+			t.Fatalf("set_file %q: expected failure %t, got %v", c.path, c.fails, err)
+		}
+	}
+}
+
+func TestResourceVClusterPlanExtraVolumes(t *testing.T) {
+	cases := []struct {
+		volumes []interface{}
+		mounts  []interface{}
+		error   string
+	}{
+		{
+			volumes: []interface{}{map[string]interface{}{"name": "config", "config_map": "vcluster-config"}},
+			mounts:  []interface{}{map[string]interface{}{"name": "config", "mount_path": "/etc/vcluster"}},
+		},
+		{
+			volumes: []interface{}{map[string]interface{}{"name": "config", "config_map": "vcluster-config", "secret": "vcluster-certs"}},
+			error:   "extra_volumes.0: exactly one of config_map, secret or empty_dir must be set",
+		},
+		{
+			volumes: []interface{}{map[string]interface{}{"name": "config", "empty_dir": false}},
+			error:   "extra_volumes.0: exactly one of config_map, secret or empty_dir must be set",
+		},
+		{
+			volumes: []interface{}{map[string]interface{}{"name": "config", "empty_dir": true}},
+			mounts:  []interface{}{map[string]interface{}{"name": "certs", "mount_path": "/etc/certs"}},
+			error:   `extra_volume_mounts.0: volume "certs" is not in extra_volumes`,
+		},
+	}
+
+	for _, c := range cases {
+		meta, _ := testMeta(t, map[string]interface{}{})
+
+		_, err := resourceVCluster().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(map[string]interface{}{
+			"name":                "test",
+			"extra_volumes":       c.volumes,
+			"extra_volume_mounts": c.mounts,
+		}), meta)
+		if c.error == "" && err != nil {
+			t.Errorf("%v: unexpected error %v", c.volumes, err)
+		}
+		if c.error != "" && (err == nil || err.Error() != c.error) {
+			t.Errorf("%v: expected the error %q, got %v", c.volumes, c.error, err)
+		}
+	}
+}
+
+func TestResourceVClusterUpdateRestartGeneration(t *testing.T) {
+	cases := []struct {
+		generation int
+		restarts   bool
+	}{
+		{generation: 1, restarts: false},
+		{generation: 2, restarts: true},
+	}
+
+	for _, c := range cases {
+		meta, runner := testMeta(t, map[string]interface{}{})
+		_, d := testVClusterPlan(t, meta,
+			map[string]interface{}{"name": "test", "restart_generation": 1, "wait_for_delete": true},
+			map[string]interface{}{"name": "test", "restart_generation": c.generation, "wait_for_delete": false},
+		)
+
+		if diags := resourceVClusterUpdate(context.Background(), d, meta); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		if runner.ran("kubectl rollout restart statefulset/test --namespace vcluster-test") != c.restarts {
+			t.Fatalf("generation %d: expected a restart %t, ran %q", c.generation, c.restarts, runner.lines())
+		}
+	}
+}
+
+func TestResourceVClusterClusterName(t *testing.T) {
+	meta, runner := testMeta(t, map[string]interface{}{})
+	runner.on("vcluster list", fakeResult{stdout: `[{"Name": "host-name", "Namespace": "team", "Status": "Running"}]`})
+	runner.on("kubectl get pods", fakeResult{stdout: `{"items": []}`})
+
+	d := testVCluster(t, map[string]interface{}{
+		"name":         "test",
+		"cluster_name": "host-name",
+		"namespace":    "team",
+	})
+	d.SetId("test")
+
+	if args := vclusterCreateArgs(d, meta); args[1] != "host-name" {
+		t.Fatalf("expected the vcluster to be created as host-name, got %q", args)
+	}
+
+	if diags := resourceVClusterRead(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if d.Id() == "" || d.Get("name").(string) != "test" || d.Get("status").(string) != "Running" {
+		t.Fatalf("expected host-name to be read into test, got id %q, name %q", d.Id(), d.Get("name"))
+	}
+
+	if diags := resourceVClusterDelete(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	runner.find(t, "vcluster delete host-name")
+}
+
+// extraValuesFiles returns the contents of the values files passed to the command, which are removed after it runs.
+func extraValuesFiles(t *testing.T, cmd *exec.Cmd) []string {
+	t.Helper()
+
+	files := []string{}
+	for i, arg := range cmd.Args {
+		if arg == "--extra-values" {
+			data, err := os.ReadFile(cmd.Args[i+1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			files = append(files, string(data))
+		}
+	}
+	return files
+}
+
+func TestApplyVClusterValuesApplied(t *testing.T) {
+	meta, runner := testMeta(t, map[string]interface{}{
+		"base_values": []interface{}{"syncer:\n  replicas: 2\n"},
+	})
+
+	var passed []string
+	meta.runner = func(cmd *exec.Cmd) error {
+		if strings.HasPrefix(strings.Join(cmd.Args, " "), "vcluster create") {
+			passed = extraValuesFiles(t, cmd)
+		}
+		return runner.run(cmd)
+	}
+
+	d := testVCluster(t, map[string]interface{}{
+		"name":             "test",
+		"syncer_log_level": 4,
+		"extra_values":     []interface{}{"syncer:\n  replicas: 3\n"},
+	})
+
+	if diags := applyVCluster(context.Background(), d, meta, false); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	composed, err := composeValues(d, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied := d.Get("values_applied").(string)
+	if applied != string(composed) {
+		t.Fatalf("expected values_applied to be the composed values %q, got %q", composed, applied)
+	}
+
+	if !reflect.DeepEqual(passed, []string{applied}) {
+		t.Fatalf("expected the values passed to the cli to be %q, got %q", applied, passed)
+	}
+}
+
+func TestApplyVClusterLastCommand(t *testing.T) {
+	meta, runner := testMeta(t, map[string]interface{}{})
+
+	d := testVCluster(t, map[string]interface{}{
+		"name": "test",
+		"set_literal": map[string]interface{}{
+			"syncer.extraEnv.apiToken": "secret-token",
+			"vcluster.image":           "rancher/k3s:v1.25.5-k3s1",
+		},
+	})
+
+	if diags := applyVCluster(context.Background(), d, meta, false); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	args := runner.find(t, "vcluster create test").Args
+	if !hasArg(args, "syncer.extraEnv.apiToken=secret-token") {
+		t.Fatalf("expected the secret to be passed to the cli, got %q", args)
+	}
+
+	expected := strings.Replace(strings.Join(args, " "), "apiToken=secret-token", "apiToken=(redacted)", 1)
+	if lastCommand := d.Get("last_command").(string); lastCommand != expected {
+		t.Fatalf("expected last_command to be %q, got %q", expected, lastCommand)
+	}
+	// values the redaction does not recognize as sensitive may still be secrets.
+	if !resourceVCluster().Schema["last_command"].Sensitive {
+		t.Fatal("expected last_command to be sensitive")
+	}
+}
+
+func TestApplyVClusterKeepValuesOnFailure(t *testing.T) {
+	for _, keep := range []bool{true, false} {
+		meta, runner := testMeta(t, map[string]interface{}{"keep_values_on_failure": keep})
+		runner.on("vcluster create", fakeResult{stdout: "fatal   release vcluster-test failed\n", err: exitError(1)})
+
+		d := testVCluster(t, map[string]interface{}{"name": "test"})
+		if diags := applyVCluster(context.Background(), d, meta, false); !diags.HasError() {
+			t.Fatal("expected the failed create to be reported")
+		}
+
+		cmd := runner.find(t, "vcluster create")
+		valuesFile := cmd.Args[len(cmd.Args)-1]
+		t.Cleanup(func() { os.Remove(valuesFile) })
+
+		if _, err := os.Stat(valuesFile); (err == nil) != keep {
+			t.Fatalf("keep_values_on_failure = %t: expected the values file to be kept to be %t, stat: %v", keep, keep, err)
+		}
+	}
+}
+
+func TestResourceVClusterCreateUpdatesKubeConfig(t *testing.T) {
+	meta, runner := testMeta(t, map[string]interface{}{})
+
+	d := testVCluster(t, map[string]interface{}{
+		"name":                   "test",
+		"update_current":         true,
+		"kube_context_name":      "dev",
+		"skip_read_after_create": true,
+	})
+
+	if diags := resourceVClusterCreate(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	if create := strings.Join(runner.find(t, "vcluster create").Args, " "); strings.Contains(create, "--update-current") {
+		t.Fatalf("expected the kube config not to be updated by create, ran %q", create)
+	}
+
+	runner.find(t, "vcluster connect test --update-current=true --kube-config-context-name=dev --background-proxy=true")
+}
+
+func TestResourceVClusterCreateAutoDeleteAfter(t *testing.T) {
+	meta, runner := testMeta(t, map[string]interface{}{})
+	runner.on("vcluster list", fakeResult{
+		stdout: `[{"Name": "test", "Status": "Running", "Created": "2022-12-09T03:12:10Z"}]`,
+	})
+	runner.on("kubectl get pods", fakeResult{stdout: `{"items": []}`})
+
+	d := testVCluster(t, map[string]interface{}{
+		"name":              "test",
+		"edition":           "pro",
+		"auto_delete_after": "24h",
+	})
+
+	if diags := resourceVClusterCreate(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	if args := runner.find(t, "vcluster create test").Args; !hasArg(args, "--auto-delete-after=24h") {
+		t.Fatalf("expected --auto-delete-after=24h in %q", args)
+	}
+	if autoDeleteAt := d.Get("auto_delete_at").(string); autoDeleteAt != "2022-12-10T03:12:10Z" {
+		t.Fatalf("expected the vcluster to delete itself a day after it was created, got %q", autoDeleteAt)
+	}
+
+	for _, ttl := range []string{"1d", "-1h", "0s"} {
+		if diags := testValidate(map[string]interface{}{"name": "test", "edition": "pro", "auto_delete_after": ttl}); !diags.HasError() {
+			t.Errorf("expected the duration %q to be rejected", ttl)
+		}
+	}
+}
+
+func TestResourceVClusterPlatformProject(t *testing.T) {
+	meta, runner := testMeta(t, map[string]interface{}{})
+	runner.on("vcluster list", fakeResult{
+		stdout: `[{"Name": "test", "Status": "Running", "Created": "2022-12-09T03:12:10Z", "Project": "team"}]`,
+	})
+	runner.on("kubectl get pods", fakeResult{stdout: `{"items": []}`})
+
+	d := testVCluster(t, map[string]interface{}{
+		"name":             "test",
+		"edition":          "pro",
+		"platform_project": "team",
+	})
+
+	if diags := resourceVClusterCreate(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if args := runner.find(t, "vcluster create test").Args; !hasArg(args, "--project=team") {
+		t.Fatalf("expected --project=team in %q", args)
+	}
+
+	// the project the platform reports is read back, so that a vcluster moved out of band shows up in the plan.
+	meta, runner = testMeta(t, map[string]interface{}{})
+	runner.on("vcluster list", fakeResult{
+		stdout: `[{"Name": "test", "Status": "Running", "Created": "2022-12-09T03:12:10Z", "Project": "ops"}]`,
+	})
+	runner.on("kubectl get pods", fakeResult{stdout: `{"items": []}`})
+
+	if diags := resourceVClusterRead(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if project := d.Get("platform_project").(string); project != "ops" {
+		t.Fatalf("expected the project to be read back, got %q", project)
+	}
+
+	d = testVCluster(t, map[string]interface{}{"name": "test"})
+	for _, arg := range vclusterCreateArgs(d, meta) {
+		if strings.HasPrefix(arg, "--project") {
+			t.Fatalf("expected no project, got %s", arg)
+		}
+	}
+}
+
+func TestResourceVClusterCreateSkipReadAfterCreate(t *testing.T) {
+	for _, skip := range []bool{true, false} {
+		meta, runner := testMeta(t, map[string]interface{}{})
+		runner.on("vcluster list", fakeResult{
+			stdout: `[{"Name": "test", "Status": "Running", "Created": "2022-12-09T03:12:10Z"}]`,
+		})
+		runner.on("kubectl get pods", fakeResult{stdout: `{"items": []}`})
+
+		d := testVCluster(t, map[string]interface{}{
+			"name":                   "test",
+			"skip_read_after_create": skip,
+		})
+
+		if diags := resourceVClusterCreate(context.Background(), d, meta); diags.HasError() {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+
+		if runner.ran("vcluster list") == skip {
+			t.Fatalf("skip_read_after_create = %t: expected the vcluster to be read to be %t, ran %q", skip, !skip, runner.lines())
+		}
+		if status := d.Get("status").(string); (status == "") != skip {
+			t.Fatalf("skip_read_after_create = %t: unexpected status %q", skip, status)
+		}
+	}
+}
+
+func TestResourceVClusterNodePort(t *testing.T) {
+	meta, _ := testMeta(t, map[string]interface{}{})
+
+	values := testValues(t, map[string]interface{}{"name": "test", "expose_local": true, "node_port": 30443})
+	expectValues(t, values, map[string]interface{}{"service.httpsNodePort": 30443})
+
+	current := testVCluster(t, map[string]interface{}{"name": "test"})
+	current.SetId("test")
+
+	cases := []struct {
+		exposeLocal bool
+		fails       bool
+	}{
+		{exposeLocal: true, fails: false},
+		{exposeLocal: false, fails: true},
+	}
+
+	for _, c := range cases {
+		_, err := resourceVCluster().Diff(context.Background(), current.State(), terraform.NewResourceConfigRaw(map[string]interface{}{
+			"name":         "test",
+			"expose_local": c.exposeLocal,
+			"node_port":    30443,
+		}), meta)
+		if (err != nil) != c.fails {
+			t.Fatalf("expose_local = %t: expected failure %t, got %v", c.exposeLocal, c.fails, err)
+		}
+	}
+}
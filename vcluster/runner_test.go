@@ -0,0 +1,184 @@
+package vcluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+
+	ctyjson "github.com/hashicorp/go-cty/cty/json"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// fakeResult is the outcome of a command run by the fake runner.
+type fakeResult struct {
+	stdout string
+	stderr string
+	err    error
+}
+
+// fakeRule answers the commands whose command line starts with its prefix.
+type fakeRule struct {
+	prefix string
+	result fakeResult
+}
+
+// fakeRunner records the commands it is asked to run and answers them with stubbed output instead of running them.
+type fakeRunner struct {
+	mu       sync.Mutex
+	rules    []fakeRule
+	commands []*exec.Cmd
+}
+
+// on stubs the result of the commands whose command line, the name followed by the arguments, starts with the prefix.
+// Rules are matched in the order they were added, commands without a matching rule succeed without output.
+func (f *fakeRunner) on(prefix string, result fakeResult) *fakeRunner {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.rules = append(f.rules, fakeRule{prefix: prefix, result: result})
+	return f
+}
+
+func (f *fakeRunner) run(cmd *exec.Cmd) error {
+	f.mu.Lock()
+	f.commands = append(f.commands, cmd)
+	rules := f.rules
+	f.mu.Unlock()
+
+	line := strings.Join(cmd.Args, " ")
+	for _, rule := range rules {
+		if strings.HasPrefix(line, rule.prefix) {
+			io.WriteString(cmd.Stdout, rule.result.stdout)
+			io.WriteString(cmd.Stderr, rule.result.stderr)
+			return rule.result.err
+		}
+	}
+
+	return nil
+}
+
+// lines returns the command lines of the commands run so far.
+func (f *fakeRunner) lines() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	lines := []string{}
+	for _, cmd := range f.commands {
+		lines = append(lines, strings.Join(cmd.Args, " "))
+	}
+	return lines
+}
+
+// ran returns true if a command whose command line starts with the prefix was run.
+func (f *fakeRunner) ran(prefix string) bool {
+	for _, line := range f.lines() {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// find returns the first command run whose command line starts with the prefix.
+func (f *fakeRunner) find(t *testing.T, prefix string) *exec.Cmd {
+	t.Helper()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, cmd := range f.commands {
+		if strings.HasPrefix(strings.Join(cmd.Args, " "), prefix) {
+			return cmd
+		}
+	}
+
+	t.Fatalf("no command starting with %q was run, ran: %q", prefix, f.commands)
+	return nil
+}
+
+// hasArg returns true if the arguments contain the argument.
+func hasArg(args []string, arg string) bool {
+	for _, a := range args {
+		if a == arg {
+			return true
+		}
+	}
+	return false
+}
+
+// exitError is the error of a command that exited with the code.
+type exitError int
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+func (e exitError) ExitCode() int {
+	return int(e)
+}
+
+// testMeta configures the provider with the raw configuration, running its commands with a fake runner.
+func testMeta(t *testing.T, raw map[string]interface{}) (*Meta, *fakeRunner) {
+	t.Helper()
+
+	m, diags := providerConfigure(schema.TestResourceDataRaw(t, Provider().Schema, raw), "")
+	if diags.HasError() {
+		t.Fatalf("configuring the provider: %v", diags)
+	}
+
+	f := &fakeRunner{}
+	meta := m.(*Meta)
+	meta.runner = f.run
+	// only the servers started by the tests are reached.
+	meta.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(address)
+		if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+			return nil, fmt.Errorf("dial %s: the tests only reach loopback addresses", address)
+		}
+		return (&net.Dialer{}).DialContext(ctx, network, address)
+	}
+	return meta, f
+}
+
+// testVCluster returns the data of a vcluster_vcluster resource with the raw configuration.
+func testVCluster(t *testing.T, raw map[string]interface{}) *schema.ResourceData {
+	t.Helper()
+
+	return schema.TestResourceDataRaw(t, resourceVCluster().Schema, raw)
+}
+
+// testVClusterConfig returns the data of a vcluster_vcluster resource with the raw configuration, which is also available
+// through GetRawConfig like it is when the resource is applied.
+func testVClusterConfig(t *testing.T, raw map[string]interface{}) *schema.ResourceData {
+	t.Helper()
+
+	r := resourceVCluster()
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := ctyjson.Unmarshal(data, r.CoreConfigSchema().ImpliedType())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := schema.InternalMap(r.Schema).Diff(context.Background(), nil, terraform.NewResourceConfigRaw(raw), nil, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff.RawConfig = config
+
+	d, err := schema.InternalMap(r.Schema).Data(nil, diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
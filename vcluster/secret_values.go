@@ -0,0 +1,52 @@
+package vcluster
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// readSecretValues reads the helm values referenced by values_from_secret from the host cluster, or returns nil when
+// no secret is referenced.
+func readSecretValues(ctx context.Context, d *schema.ResourceData, meta *Meta) ([]byte, diag.Diagnostics) {
+	ref, ok := firstBlock(d, "values_from_secret")
+	if !ok {
+		return nil, nil
+	}
+
+	namespace := ref["namespace"].(string)
+	if namespace == "" {
+		namespace = vclusterNamespace(d)
+	}
+
+	clientset, err := kubernetesClientset(d, meta)
+	if err != nil {
+		return nil, diag.FromErr(err)
+	}
+
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(ctx, ref["name"].(string), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  "the values secret does not exist",
+			Detail:   fmt.Sprintf("secret %s/%s referenced by values_from_secret was not found in the host cluster", namespace, ref["name"].(string)),
+		}}
+	} else if err != nil {
+		return nil, diag.FromErr(err)
+	}
+
+	values, ok := secret.Data[ref["key"].(string)]
+	if !ok {
+		return nil, diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  "the values secret has no such key",
+			Detail:   fmt.Sprintf("secret %s/%s referenced by values_from_secret has no key %q", namespace, ref["name"].(string), ref["key"].(string)),
+		}}
+	}
+
+	return values, nil
+}
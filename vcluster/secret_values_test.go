@@ -0,0 +1,60 @@
+package vcluster
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReadSecretValues(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "vcluster-values", Namespace: "team"},
+		Data:       map[string][]byte{"values.yaml": []byte("syncer:\n  replicas: 2\n")},
+	}
+
+	cases := []struct {
+		ref    map[string]interface{}
+		values string
+		fails  bool
+	}{
+		{
+			ref:    map[string]interface{}{"name": "vcluster-values", "key": "values.yaml"},
+			values: "syncer:\n  replicas: 2\n",
+		},
+		{
+			ref:   map[string]interface{}{"name": "vcluster-values", "key": "missing.yaml"},
+			fails: true,
+		},
+		{
+			ref:   map[string]interface{}{"name": "missing", "key": "values.yaml"},
+			fails: true,
+		},
+	}
+
+	for _, c := range cases {
+		meta, _, hosts := testClientset(t, secret)
+		meta.clusterAliases = map[string]string{"staging": "two"}
+
+		d := testVCluster(t, map[string]interface{}{
+			"name":               "test",
+			"namespace":          "team",
+			"context":            "staging",
+			"values_from_secret": []interface{}{c.ref},
+		})
+
+		values, diags := readSecretValues(context.Background(), d, meta)
+		if diags.HasError() != c.fails {
+			t.Fatalf("reading %v: unexpected diagnostics %v", c.ref, diags)
+		}
+		if string(values) != c.values {
+			t.Fatalf("reading %v: expected %q, got %q", c.ref, c.values, values)
+		}
+
+		if expected := []string{"https://two.example.com"}; !reflect.DeepEqual(*hosts, expected) {
+			t.Fatalf("expected the secret to be read from the cluster of the aliased context, read from %q", *hosts)
+		}
+	}
+}
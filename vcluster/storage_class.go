@@ -0,0 +1,49 @@
+package vcluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// resolveStorageClass returns the first of the candidate storage classes that exists in the host cluster.
+func resolveStorageClass(ctx context.Context, d *schema.ResourceData, meta *Meta, candidates []string) (string, diag.Diagnostics) {
+	args := []string{
+		"get", "storageclasses",
+		"--output", "name",
+	}
+
+	if context := vclusterContext(d, meta); context != "" {
+		args = append(args, "--context", context)
+	}
+
+	output, diags := runKubectl(ctx, meta, args)
+	if diags.HasError() {
+		return "", diags
+	}
+
+	available := map[string]bool{}
+	for _, line := range strings.Split(string(output), "\n") {
+		// kubectl prints the storage classes as storageclass.storage.k8s.io/<name>
+		if name := strings.TrimSpace(line[strings.LastIndex(line, "/")+1:]); name != "" {
+			available[name] = true
+		}
+	}
+
+	for _, candidate := range candidates {
+		if available[candidate] {
+			return candidate, nil
+		}
+	}
+
+	return "", diag.Diagnostics{
+		{
+			Severity: diag.Error,
+			Summary:  "none of the storage class candidates exist in the host cluster",
+			Detail:   fmt.Sprintf("candidates: %s", strings.Join(candidates, ", ")),
+		},
+	}
+}
@@ -0,0 +1,56 @@
+package vcluster
+
+import (
+	"context"
+	"testing"
+)
+
+func TestResolveStorageClass(t *testing.T) {
+	meta, runner := testMeta(t, map[string]interface{}{})
+	runner.on("kubectl get storageclasses", fakeResult{
+		stdout: "storageclass.storage.k8s.io/standard\nstorageclass.storage.k8s.io/gp3\n",
+	})
+
+	d := testVCluster(t, map[string]interface{}{
+		"name":    "test",
+		"context": "kind-host",
+	})
+
+	cases := []struct {
+		candidates []string
+		expected   string
+		fails      bool
+	}{
+		{candidates: []string{"gp3", "standard"}, expected: "gp3"},
+		{candidates: []string{"premium", "standard"}, expected: "standard"},
+		{candidates: []string{"premium"}, fails: true},
+	}
+
+	for _, c := range cases {
+		class, diags := resolveStorageClass(context.Background(), d, meta, c.candidates)
+		if diags.HasError() != c.fails {
+			t.Fatalf("resolving %q: unexpected diagnostics %v", c.candidates, diags)
+		}
+		if class != c.expected {
+			t.Fatalf("resolving %q: expected %q, got %q", c.candidates, c.expected, class)
+		}
+	}
+
+	cmd := runner.find(t, "kubectl get storageclasses")
+	if args := cmd.Args[len(cmd.Args)-2:]; args[0] != "--context" || args[1] != "kind-host" {
+		t.Fatalf("expected the context of the resource to be passed, got %q", cmd.Args)
+	}
+}
+
+func TestResolveStorageClassFailure(t *testing.T) {
+	meta, runner := testMeta(t, map[string]interface{}{})
+	runner.on("kubectl get storageclasses", fakeResult{
+		stderr: "The connection to the server localhost:8080 was refused",
+		err:    exitError(1),
+	})
+
+	d := testVCluster(t, map[string]interface{}{"name": "test"})
+	if _, diags := resolveStorageClass(context.Background(), d, meta, []string{"standard"}); !diags.HasError() {
+		t.Fatal("expected the failure of kubectl to be reported")
+	}
+}
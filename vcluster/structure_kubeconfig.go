@@ -121,7 +121,9 @@ func (k *KubeConfig) ToRawKubeConfigLoader() clientcmd.ClientConfig {
 	return k.ClientConfig
 }
 
-func newKubeConfig(configData *schema.ResourceData, namespace *string) (*KubeConfig, error) {
+// newKubeConfig returns the client config of the kubernetes block, using the context when it is not empty. Without a
+// config path or host the kube config is loaded like kubectl and the vcluster cli do.
+func newKubeConfig(configData *schema.ResourceData, namespace *string, context string) (*KubeConfig, error) {
 	overrides := &clientcmd.ConfigOverrides{}
 	loader := &clientcmd.ClientConfigLoadingRules{}
 
@@ -175,6 +177,13 @@ func newKubeConfig(configData *schema.ResourceData, namespace *string) (*KubeCon
 			}
 			log.Printf("[DEBUG] Using overidden context: %#v", overrides.Context)
 		}
+	} else if _, ok := k8sGetOk(configData, "host"); !ok {
+		loader = clientcmd.NewDefaultClientConfigLoadingRules()
+	}
+
+	if context != "" {
+		overrides.CurrentContext = context
+		log.Printf("[DEBUG] Using custom current context: %q", overrides.CurrentContext)
 	}
 
 	// Overriding with static configuration
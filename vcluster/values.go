@@ -0,0 +1,540 @@
+package vcluster
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/yaml"
+)
+
+// fromHostSyncKinds maps the resource kinds that can be synced from the host to their key in the helm values.
+var fromHostSyncKinds = map[string]string{
+	"configmaps":      "configMaps",
+	"secrets":         "secrets",
+	"storageclasses":  "storageClasses",
+	"ingressclasses":  "ingressClasses",
+	"priorityclasses": "priorityClasses",
+}
+
+// vclusterValues renders the helm values modeled by the attributes of the vcluster resource.
+func vclusterValues(d *schema.ResourceData) map[string]interface{} {
+	values := map[string]interface{}{}
+
+	if storageClass := d.Get("storage_class"); storageClass != nil && storageClass.(string) != "" {
+		setValue(values, "storage.className", storageClass.(string))
+	}
+
+	if serviceCIDR := d.Get("service_cidr"); serviceCIDR != nil && serviceCIDR.(string) != "" {
+		setValue(values, "serviceCIDR", serviceCIDR.(string))
+	}
+
+	if clusterDomain := d.Get("cluster_domain"); clusterDomain != nil && clusterDomain.(string) != "" {
+		appendValue(values, "vcluster.extraArgs", "--cluster-domain="+clusterDomain.(string))
+		appendValue(values, "syncer.extraArgs", "--cluster-domain="+clusterDomain.(string))
+	}
+
+	if d.Get("disable_coredns").(bool) {
+		setValue(values, "coredns.enabled", false)
+	}
+
+	if address := d.Get("advertise_address"); address != nil && address.(string) != "" {
+		appendValue(values, "vcluster.extraArgs", "--advertise-address="+address.(string))
+		// the certificate of the api server must be valid for the address clients connect to.
+		appendValue(values, "syncer.extraArgs", "--tls-san="+address.(string))
+	}
+
+	if defaultStorageClass := d.Get("default_storage_class"); defaultStorageClass != nil && defaultStorageClass.(string) != "" {
+		setValue(values, "sync.persistentvolumeclaims.defaultStorageClassName", defaultStorageClass.(string))
+	}
+
+	if nodePort, ok := d.GetOk("node_port"); ok && d.Get("expose_local").(bool) {
+		setValue(values, "service.httpsNodePort", nodePort.(int))
+	}
+
+	if audience := d.Get("sa_token_audience"); audience != nil && audience.(string) != "" {
+		setValue(values, "serviceAccount.tokenAudience", audience.(string))
+	}
+
+	if selector := d.Get("sync_exclude_selector"); selector != nil && selector.(string) != "" {
+		setValue(values, "sync.excludeLabelSelector", selector.(string))
+	}
+
+	if selector := expandStringMap(d.Get("enforce_node_selector").(map[string]interface{})); len(selector) > 0 {
+		terms := []string{}
+		for _, key := range mapKeys(selector) {
+			terms = append(terms, key+"="+selector[key])
+		}
+
+		appendValue(values, "syncer.extraArgs", "--node-selector="+strings.Join(terms, ","))
+		appendValue(values, "syncer.extraArgs", "--enforce-node-selector")
+	}
+
+	if scheduler := d.Get("scheduler_name"); scheduler != nil && scheduler.(string) != "" {
+		setValue(values, "sync.pods.schedulerName", scheduler.(string))
+	}
+
+	if logLevel := d.Get("syncer_log_level").(int); configuredInt(d, cty.GetAttrPath("syncer_log_level"), logLevel) {
+		appendValue(values, "syncer.extraArgs", fmt.Sprintf("--v=%d", logLevel))
+	}
+
+	if webhooks, ok := firstBlock(d, "webhooks"); ok {
+		for _, kind := range []string{"validating", "mutating"} {
+			setWebhookSyncValues(values, kind+"webhookconfigurations", webhooks[kind].(string))
+		}
+	}
+
+	if fromHost, ok := firstBlock(d, "from_host_sync"); ok {
+		for _, kind := range expandStringSlice(fromHost["kinds"].([]interface{})) {
+			setValue(values, "sync.fromHost."+fromHostSyncKinds[kind]+".enabled", true)
+		}
+	}
+
+	if hostRewrite, ok := firstBlock(d, "host_rewrite"); ok {
+		rules := []interface{}{}
+		for _, v := range hostRewrite["rule"].([]interface{}) {
+			rule := v.(map[string]interface{})
+			rules = append(rules, map[string]interface{}{
+				"from": rule["virtual"].(string),
+				"to":   rule["host"].(string),
+			})
+		}
+
+		setValue(values, "mapServices.fromVirtual", rules)
+	}
+
+	if mode := d.Get("secret_sync"); mode != nil && mode.(string) != "" {
+		setValue(values, "sync.secrets.enabled", mode.(string) == "to-host")
+		setValue(values, "sync.fromHost.secrets.enabled", mode.(string) == "from-host")
+	}
+
+	if ingress, ok := firstBlock(d, "ingress"); ok {
+		setValue(values, "ingress.enabled", ingress["enabled"].(bool))
+
+		if host := ingress["host"].(string); host != "" {
+			setValue(values, "ingress.host", host)
+		}
+
+		if class := ingress["class"].(string); class != "" {
+			setValue(values, "ingress.ingressClassName", class)
+		}
+
+		if annotations := ingress["annotations"].(map[string]interface{}); len(annotations) > 0 {
+			setValue(values, "ingress.annotations", annotations)
+		}
+
+		if secret := ingress["tls_secret"].(string); secret != "" {
+			setValue(values, "ingress.tls", []interface{}{
+				map[string]interface{}{
+					"hosts":      []interface{}{ingress["host"].(string)},
+					"secretName": secret,
+				},
+			})
+		}
+	}
+
+	if priorityClass := d.Get("priority_class_name"); priorityClass != nil && priorityClass.(string) != "" {
+		setValue(values, "priorityClassName", priorityClass.(string))
+	}
+
+	// 0 is a valid grace period, so it is rendered whenever it is configured.
+	if gracePeriod := d.Get("termination_grace_period_seconds").(int); configuredInt(d, cty.GetAttrPath("termination_grace_period_seconds"), gracePeriod) {
+		setValue(values, "terminationGracePeriodSeconds", gracePeriod)
+	}
+
+	if annotations := d.Get("pod_annotations").(map[string]interface{}); len(annotations) > 0 {
+		setValue(values, "podAnnotations", annotations)
+	}
+
+	if initContainers := d.Get("init_containers").([]interface{}); len(initContainers) > 0 {
+		containers := []interface{}{}
+		for _, v := range initContainers {
+			initContainer := v.(map[string]interface{})
+			container := map[string]interface{}{
+				"name":  initContainer["name"].(string),
+				"image": initContainer["image"].(string),
+			}
+
+			if command := initContainer["command"].([]interface{}); len(command) > 0 {
+				container["command"] = command
+			}
+
+			if args := initContainer["args"].([]interface{}); len(args) > 0 {
+				container["args"] = args
+			}
+
+			if env := expandStringMap(initContainer["env"].(map[string]interface{})); len(env) > 0 {
+				vars := []interface{}{}
+				for _, name := range mapKeys(env) {
+					vars = append(vars, map[string]interface{}{"name": name, "value": env[name]})
+				}
+				container["env"] = vars
+			}
+
+			containers = append(containers, container)
+		}
+
+		setValue(values, "initContainers", containers)
+	}
+
+	if extraVolumes := d.Get("extra_volumes").([]interface{}); len(extraVolumes) > 0 {
+		volumes := []interface{}{}
+		for _, v := range extraVolumes {
+			extraVolume := v.(map[string]interface{})
+			volume := map[string]interface{}{"name": extraVolume["name"].(string)}
+
+			switch {
+			case extraVolume["config_map"].(string) != "":
+				volume["configMap"] = map[string]interface{}{"name": extraVolume["config_map"].(string)}
+			case extraVolume["secret"].(string) != "":
+				volume["secret"] = map[string]interface{}{"secretName": extraVolume["secret"].(string)}
+			default:
+				volume["emptyDir"] = map[string]interface{}{}
+			}
+
+			volumes = append(volumes, volume)
+		}
+
+		setValue(values, "volumes", volumes)
+	}
+
+	if extraVolumeMounts := d.Get("extra_volume_mounts").([]interface{}); len(extraVolumeMounts) > 0 {
+		mounts := []interface{}{}
+		for _, v := range extraVolumeMounts {
+			extraMount := v.(map[string]interface{})
+			mount := map[string]interface{}{
+				"name":      extraMount["name"].(string),
+				"mountPath": extraMount["mount_path"].(string),
+				"readOnly":  extraMount["read_only"].(bool),
+			}
+
+			if subPath := extraMount["sub_path"].(string); subPath != "" {
+				mount["subPath"] = subPath
+			}
+
+			mounts = append(mounts, mount)
+		}
+
+		setValue(values, "vcluster.volumeMounts", mounts)
+	}
+
+	if antiAffinity, ok := firstBlock(d, "anti_affinity"); ok {
+		term := map[string]interface{}{
+			"labelSelector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{
+					"app":     "vcluster",
+					"release": vclusterReleaseName(d),
+				},
+			},
+			"topologyKey": antiAffinity["topology_key"].(string),
+		}
+
+		if antiAffinity["required"].(bool) {
+			setValue(values, "affinity.podAntiAffinity.requiredDuringSchedulingIgnoredDuringExecution", []interface{}{term})
+		} else {
+			setValue(values, "affinity.podAntiAffinity.preferredDuringSchedulingIgnoredDuringExecution", []interface{}{
+				map[string]interface{}{
+					"weight":          100,
+					"podAffinityTerm": term,
+				},
+			})
+		}
+	}
+
+	if signingCA, ok := firstBlock(d, "signing_ca"); ok {
+		setValue(values, "certs.ca.crt", signingCA["cert"].(string))
+		setValue(values, "certs.ca.key", signingCA["key"].(string))
+	}
+
+	if secret := d.Get("credentials_secret"); secret != nil && secret.(string) != "" {
+		setValue(values, "certs.existingSecret", secret.(string))
+	}
+
+	if probes, ok := firstBlock(d, "probes"); ok {
+		for _, probe := range []string{"liveness", "readiness"} {
+			blocks := probes[probe].([]interface{})
+			if len(blocks) == 0 || blocks[0] == nil {
+				continue
+			}
+
+			settings := blocks[0].(map[string]interface{})
+			for key, value := range map[string]string{
+				"initial_delay_seconds": "initialDelaySeconds",
+				"period_seconds":        "periodSeconds",
+				"failure_threshold":     "failureThreshold",
+			} {
+				path := cty.GetAttrPath("probes").IndexInt(0).GetAttr(probe).IndexInt(0).GetAttr(key)
+				if configuredInt(d, path, settings[key].(int)) {
+					setValue(values, "syncer."+probe+"Probe."+value, settings[key].(int))
+				}
+			}
+		}
+	}
+
+	if metrics, ok := firstBlock(d, "metrics"); ok {
+		setValue(values, "metrics.enabled", metrics["enabled"].(bool))
+		setValue(values, "metrics.port", metrics["port"].(int))
+		setValue(values, "monitoring.serviceMonitor.enabled", metrics["enabled"].(bool) && metrics["service_monitor"].(bool))
+	}
+
+	if rbac, ok := firstBlock(d, "rbac"); ok {
+		setValue(values, "rbac.clusterRole.create", rbac["scope"].(string) == "cluster")
+		setValue(values, "rbac.role.create", true)
+	}
+
+	if proxy, ok := firstBlock(d, "egress_proxy"); ok {
+		env := map[string]string{
+			"HTTP_PROXY":  proxy["http_proxy"].(string),
+			"HTTPS_PROXY": proxy["https_proxy"].(string),
+			"NO_PROXY":    strings.Join(expandStringSlice(proxy["no_proxy"].([]interface{})), ","),
+		}
+
+		for _, name := range mapKeys(env) {
+			if env[name] == "" {
+				continue
+			}
+
+			for _, container := range []string{"vcluster", "syncer"} {
+				appendValue(values, container+".env", map[string]interface{}{"name": name, "value": env[name]})
+			}
+		}
+	}
+
+	if audit, ok := firstBlock(d, "audit"); ok && audit["enabled"].(bool) {
+		var policy map[string]interface{}
+		// the policy is validated by the schema.
+		_ = yaml.Unmarshal([]byte(audit["policy"].(string)), &policy)
+
+		setValue(values, "audit.enabled", true)
+		setValue(values, "audit.policy", policy)
+		appendValue(values, "vcluster.extraArgs", "--audit-log-path="+audit["log_path"].(string))
+	}
+
+	if registry, ok := firstBlock(d, "registry"); ok {
+		setValue(values, "registry.enabled", registry["enabled"].(bool))
+		setValue(values, "registry.persistence.enabled", registry["persistence"].(bool))
+		if registry["persistence"].(bool) {
+			setValue(values, "registry.persistence.size", registry["storage_size"].(string))
+		}
+	}
+
+	if renewal, ok := firstBlock(d, "cert_renewal"); ok {
+		setValue(values, "certs.renewal.enabled", renewal["enabled"].(bool))
+		setValue(values, "certs.renewal.beforeExpiry", renewal["before_expiry"].(string))
+	}
+
+	if pdb, ok := firstBlock(d, "pdb"); ok {
+		setValue(values, "podDisruptionBudget.enabled", pdb["enabled"].(bool))
+
+		if minAvailable := pdb["min_available"].(string); minAvailable != "" {
+			setValue(values, "podDisruptionBudget.minAvailable", intstr.Parse(minAvailable))
+		}
+
+		if maxUnavailable := pdb["max_unavailable"].(string); maxUnavailable != "" {
+			setValue(values, "podDisruptionBudget.maxUnavailable", intstr.Parse(maxUnavailable))
+		}
+	}
+
+	return values
+}
+
+// setWebhookSyncValues renders the syncer values for the admission webhook configuration kind according to its mode.
+func setWebhookSyncValues(values map[string]interface{}, kind string, mode string) {
+	switch mode {
+	case "sync":
+		setValue(values, "sync."+kind+".enabled", true)
+	case "fake":
+		setValue(values, "sync."+kind+".enabled", false)
+		setValue(values, "sync.fake-"+kind+".enabled", true)
+	case "disabled":
+		setValue(values, "sync."+kind+".enabled", false)
+	}
+}
+
+// firstBlock returns the attributes of a block with MaxItems 1, or false if it is not configured.
+func firstBlock(d *schema.ResourceData, key string) (map[string]interface{}, bool) {
+	v, ok := d.GetOk(key)
+	if !ok {
+		return nil, false
+	}
+
+	block, ok := v.([]interface{})[0].(map[string]interface{})
+	return block, ok
+}
+
+// configuredInt returns true if the integer attribute at the path is set in the configuration, which tells a configured
+// zero apart from an unset attribute. When the configuration is not available only non-zero values are set.
+func configuredInt(d *schema.ResourceData, path cty.Path, value int) bool {
+	config := d.GetRawConfig()
+	if config.IsNull() {
+		return value != 0
+	}
+
+	v, err := path.Apply(config)
+	return err == nil && !v.IsNull()
+}
+
+// setValue sets the value at the dot separated path, creating any intermediate maps.
+func setValue(values map[string]interface{}, path string, value interface{}) {
+	keys := strings.Split(path, ".")
+
+	current := values
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			current[key] = next
+		}
+
+		current = next
+	}
+
+	current[keys[len(keys)-1]] = value
+}
+
+// composeValues renders the helm values of the vcluster as yaml. The base_values of the provider come first, then the
+// values modeled by the resource's attributes, and then the extra_values in the order they are listed, each overriding
+// the ones before it.
+func composeValues(d *schema.ResourceData, meta *Meta) ([]byte, error) {
+	values, err := parseBaseValues(meta.baseValues)
+	if err != nil {
+		return nil, err
+	}
+
+	mergeValues(values, vclusterValues(d))
+	return mergeExtraValues(values, expandStringSlice(d.Get("extra_values").([]interface{})))
+}
+
+// parseBaseValues merges the raw yaml base values of the provider in order.
+func parseBaseValues(baseValues []string) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	for i, base := range baseValues {
+		var baseValues map[string]interface{}
+		if err := yaml.Unmarshal([]byte(base), &baseValues); err != nil {
+			return nil, fmt.Errorf("base_values.%d: %w", i, err)
+		}
+
+		mergeValues(values, baseValues)
+	}
+
+	return values, nil
+}
+
+// mergeExtraValues merges the raw yaml extra values over the values in order, returning the result as yaml.
+func mergeExtraValues(values map[string]interface{}, extraValues []string) ([]byte, error) {
+	for i, extra := range extraValues {
+		var extraValues map[string]interface{}
+		if err := yaml.Unmarshal([]byte(extra), &extraValues); err != nil {
+			return nil, fmt.Errorf("extra_values.%d: %w", i, err)
+		}
+
+		mergeValues(values, extraValues)
+	}
+
+	return yaml.Marshal(values)
+}
+
+// exportConfig assembles the effective configuration of the vcluster as yaml, so that it can be reapplied elsewhere.
+func exportConfig(d *schema.ResourceData, values []byte) (string, error) {
+	var parsed map[string]interface{}
+	if err := yaml.Unmarshal(values, &parsed); err != nil {
+		return "", err
+	}
+
+	config := map[string]interface{}{
+		"name":   vclusterName(d),
+		"values": parsed,
+	}
+
+	if distro := vclusterDistro(d); distro != "" {
+		config["distro"] = distro
+	}
+
+	for _, key := range []string{"namespace", "kubernetes_version", "chart", "chart_version", "chart_repo"} {
+		if v := d.Get(key); v != nil && v.(string) != "" {
+			config[key] = v.(string)
+		}
+	}
+
+	for _, key := range []string{"expose", "expose_local", "isolate"} {
+		config[key] = d.Get(key).(bool)
+	}
+
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// mergeValues deep merges the overrides into the values, like helm merges multiple values files.
+func mergeValues(values map[string]interface{}, overrides map[string]interface{}) {
+	for key, override := range overrides {
+		overrideMap, overrideIsMap := override.(map[string]interface{})
+		valueMap, valueIsMap := values[key].(map[string]interface{})
+
+		if overrideIsMap && valueIsMap {
+			mergeValues(valueMap, overrideMap)
+		} else {
+			values[key] = override
+		}
+	}
+}
+
+// appendValue appends the value to the list at the dot separated path, creating the list if needed.
+func appendValue(values map[string]interface{}, path string, value interface{}) {
+	var list []interface{}
+	if existing, ok := getValue(values, path).([]interface{}); ok {
+		list = existing
+	}
+
+	setValue(values, path, append(list, value))
+}
+
+// getValue returns the value at the dot separated path, or nil if it is not set.
+func getValue(values map[string]interface{}, path string) interface{} {
+	var current interface{} = values
+	for _, key := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		current = m[key]
+	}
+
+	return current
+}
+
+// writeValuesFile writes the yaml values to a temporary file that can be passed to the vcluster cli, returning its
+// path. The caller is responsible for removing the file.
+func writeValuesFile(data []byte) (string, error) {
+	file, err := os.CreateTemp("", "vcluster-values-*.yaml")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	return file.Name(), nil
+}
+
+// mapKeys returns the sorted keys of the map.
+func mapKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,1016 @@
+package vcluster
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/yaml"
+)
+
+// testValues renders the helm values of a vcluster resource with the raw configuration.
+func testValues(t *testing.T, raw map[string]interface{}) map[string]interface{} {
+	t.Helper()
+
+	return vclusterValues(testVCluster(t, raw))
+}
+
+// expectValues fails the test unless the values at the paths are the expected ones, a nil value is expected unset.
+func expectValues(t *testing.T, values map[string]interface{}, expected map[string]interface{}) {
+	t.Helper()
+
+	for path, value := range expected {
+		if actual := getValue(values, path); !reflect.DeepEqual(actual, value) {
+			t.Errorf("%s: expected %#v, got %#v", path, value, actual)
+		}
+	}
+}
+
+func TestVClusterValuesWebhooks(t *testing.T) {
+	values := testValues(t, map[string]interface{}{
+		"name": "test",
+		"webhooks": []interface{}{map[string]interface{}{
+			"validating": "sync",
+			"mutating":   "fake",
+		}},
+	})
+
+	expectValues(t, values, map[string]interface{}{
+		"sync.validatingwebhookconfigurations.enabled":      true,
+		"sync.fake-validatingwebhookconfigurations.enabled": nil,
+		"sync.mutatingwebhookconfigurations.enabled":        false,
+		"sync.fake-mutatingwebhookconfigurations.enabled":   true,
+	})
+
+	values = map[string]interface{}{}
+	setWebhookSyncValues(values, "validatingwebhookconfigurations", "disabled")
+	expectValues(t, values, map[string]interface{}{
+		"sync.validatingwebhookconfigurations.enabled":      false,
+		"sync.fake-validatingwebhookconfigurations.enabled": nil,
+	})
+}
+
+func TestVClusterValuesPDB(t *testing.T) {
+	values := testValues(t, map[string]interface{}{
+		"name": "test",
+		"pdb":  []interface{}{map[string]interface{}{"min_available": "1"}},
+	})
+	expectValues(t, values, map[string]interface{}{
+		"podDisruptionBudget.enabled":        true,
+		"podDisruptionBudget.minAvailable":   intstr.FromInt(1),
+		"podDisruptionBudget.maxUnavailable": nil,
+	})
+
+	values = testValues(t, map[string]interface{}{
+		"name": "test",
+		"pdb":  []interface{}{map[string]interface{}{"max_unavailable": "50%"}},
+	})
+	expectValues(t, values, map[string]interface{}{
+		"podDisruptionBudget.enabled":        true,
+		"podDisruptionBudget.minAvailable":   nil,
+		"podDisruptionBudget.maxUnavailable": intstr.FromString("50%"),
+	})
+
+	diags := testValidate(map[string]interface{}{
+		"name": "test",
+		"pdb":  []interface{}{map[string]interface{}{"min_available": "1"}},
+	})
+	if diags.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	diags = testValidate(map[string]interface{}{
+		"name": "test",
+		"pdb":  []interface{}{map[string]interface{}{"min_available": "1", "max_unavailable": "1"}},
+	})
+	if !diags.HasError() {
+		t.Fatal("expected min_available and max_unavailable to conflict")
+	}
+}
+
+func TestVClusterValuesTokenAudience(t *testing.T) {
+	values := testValues(t, map[string]interface{}{"name": "test", "sa_token_audience": "sts.amazonaws.com"})
+	expectValues(t, values, map[string]interface{}{
+		"serviceAccount.tokenAudience": "sts.amazonaws.com",
+	})
+
+	values = testValues(t, map[string]interface{}{"name": "test"})
+	expectValues(t, values, map[string]interface{}{
+		"serviceAccount": nil,
+	})
+
+	if diags := testValidate(map[string]interface{}{"name": "test", "sa_token_audience": ""}); !diags.HasError() {
+		t.Fatal("expected an empty audience to be rejected")
+	}
+}
+
+func TestVClusterValuesFromHostSync(t *testing.T) {
+	values := testValues(t, map[string]interface{}{
+		"name": "test",
+		"from_host_sync": []interface{}{map[string]interface{}{
+			"kinds": []interface{}{"configmaps", "storageclasses", "priorityclasses"},
+		}},
+	})
+
+	expectValues(t, values, map[string]interface{}{
+		"sync.fromHost.configMaps.enabled":      true,
+		"sync.fromHost.storageClasses.enabled":  true,
+		"sync.fromHost.priorityClasses.enabled": true,
+		"sync.fromHost.secrets":                 nil,
+		"sync.fromHost.ingressClasses":          nil,
+	})
+
+	diags := testValidate(map[string]interface{}{
+		"name":           "test",
+		"from_host_sync": []interface{}{map[string]interface{}{"kinds": []interface{}{"pods"}}},
+	})
+	if !diags.HasError() {
+		t.Fatal("expected an unsupported kind to be rejected")
+	}
+}
+
+func TestVClusterValuesIngress(t *testing.T) {
+	values := testValues(t, map[string]interface{}{
+		"name": "test",
+		"ingress": []interface{}{map[string]interface{}{
+			"host":        "vcluster.example.com",
+			"class":       "nginx",
+			"annotations": map[string]interface{}{"nginx.ingress.kubernetes.io/backend-protocol": "HTTPS"},
+			"tls_secret":  "vcluster-tls",
+		}},
+	})
+
+	expectValues(t, values, map[string]interface{}{
+		"ingress.enabled":          true,
+		"ingress.host":             "vcluster.example.com",
+		"ingress.ingressClassName": "nginx",
+		"ingress.annotations": map[string]interface{}{
+			"nginx.ingress.kubernetes.io/backend-protocol": "HTTPS",
+		},
+		"ingress.tls": []interface{}{
+			map[string]interface{}{
+				"hosts":      []interface{}{"vcluster.example.com"},
+				"secretName": "vcluster-tls",
+			},
+		},
+	})
+
+	values = testValues(t, map[string]interface{}{
+		"name":    "test",
+		"ingress": []interface{}{map[string]interface{}{"enabled": false}},
+	})
+	expectValues(t, values, map[string]interface{}{
+		"ingress": map[string]interface{}{"enabled": false},
+	})
+}
+
+func TestVClusterValuesAntiAffinity(t *testing.T) {
+	term := map[string]interface{}{
+		"labelSelector": map[string]interface{}{
+			"matchLabels": map[string]interface{}{"app": "vcluster", "release": "test"},
+		},
+		"topologyKey": "topology.kubernetes.io/zone",
+	}
+
+	values := testValues(t, map[string]interface{}{
+		"name": "test",
+		"anti_affinity": []interface{}{map[string]interface{}{
+			"topology_key": "topology.kubernetes.io/zone",
+			"required":     true,
+		}},
+	})
+	expectValues(t, values, map[string]interface{}{
+		"affinity.podAntiAffinity.requiredDuringSchedulingIgnoredDuringExecution":  []interface{}{term},
+		"affinity.podAntiAffinity.preferredDuringSchedulingIgnoredDuringExecution": nil,
+	})
+
+	values = testValues(t, map[string]interface{}{
+		"name": "test",
+		"anti_affinity": []interface{}{map[string]interface{}{
+			"topology_key": "topology.kubernetes.io/zone",
+		}},
+	})
+	expectValues(t, values, map[string]interface{}{
+		"affinity.podAntiAffinity.requiredDuringSchedulingIgnoredDuringExecution": nil,
+		"affinity.podAntiAffinity.preferredDuringSchedulingIgnoredDuringExecution": []interface{}{
+			map[string]interface{}{"weight": 100, "podAffinityTerm": term},
+		},
+	})
+}
+
+// testCertificate returns a PEM encoded self-signed CA certificate expiring at notAfter and its private key.
+func testCertificate(t *testing.T, notAfter time.Time) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "vcluster-ca"},
+		NotBefore:             notAfter.Add(-24 * time.Hour),
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+}
+
+func TestVClusterValuesSigningCA(t *testing.T) {
+	cert, key := testCertificate(t, time.Now().Add(365*24*time.Hour))
+
+	values := testValues(t, map[string]interface{}{
+		"name":       "test",
+		"signing_ca": []interface{}{map[string]interface{}{"cert": cert, "key": key}},
+	})
+	expectValues(t, values, map[string]interface{}{
+		"certs.ca.crt": cert,
+		"certs.ca.key": key,
+	})
+
+	cases := []struct {
+		blockType string
+		value     string
+		valid     bool
+	}{
+		{blockType: "CERTIFICATE", value: cert, valid: true},
+		{blockType: "PRIVATE KEY", value: key, valid: true},
+		{blockType: "CERTIFICATE", value: key, valid: false},
+		{blockType: "PRIVATE KEY", value: cert, valid: false},
+		{blockType: "CERTIFICATE", value: "not a certificate", valid: false},
+	}
+
+	for _, c := range cases {
+		if diags := validatePEM(c.blockType)(c.value, cty.GetAttrPath("cert")); diags.HasError() == c.valid {
+			t.Errorf("validating a %s: expected valid %t, got %v", c.blockType, c.valid, diags)
+		}
+	}
+}
+
+func TestVClusterValuesSyncerLogLevel(t *testing.T) {
+	values := testValues(t, map[string]interface{}{"name": "test", "syncer_log_level": 4})
+	expectValues(t, values, map[string]interface{}{
+		"syncer.extraArgs": []interface{}{"--v=4"},
+	})
+
+	// 0 is the quietest level, which is only rendered when it is configured.
+	values = vclusterValues(testVClusterConfig(t, map[string]interface{}{"name": "test", "syncer_log_level": 0}))
+	expectValues(t, values, map[string]interface{}{
+		"syncer.extraArgs": []interface{}{"--v=0"},
+	})
+
+	values = vclusterValues(testVClusterConfig(t, map[string]interface{}{"name": "test"}))
+	expectValues(t, values, map[string]interface{}{
+		"syncer.extraArgs": nil,
+	})
+
+	if diags := testValidate(map[string]interface{}{"name": "test", "syncer_log_level": 11}); !diags.HasError() {
+		t.Fatal("expected a log level above 10 to be rejected")
+	}
+}
+
+func TestVClusterValuesCredentialsSecret(t *testing.T) {
+	values := testValues(t, map[string]interface{}{"name": "test", "credentials_secret": "vcluster-certs"})
+	expectValues(t, values, map[string]interface{}{
+		"certs.existingSecret": "vcluster-certs",
+	})
+
+	if diags := testValidate(map[string]interface{}{"name": "test", "credentials_secret": "Not_A_Name"}); !diags.HasError() {
+		t.Fatal("expected an invalid secret name to be rejected")
+	}
+}
+
+func TestExportConfig(t *testing.T) {
+	d := testVCluster(t, map[string]interface{}{
+		"name":               "test",
+		"namespace":          "team",
+		"distro":             "k8s",
+		"kubernetes_version": "1.25",
+		"chart":              "vcluster-k8s",
+		"chart_version":      "0.13.0",
+		"chart_repo":         "https://charts.example.com",
+		"expose":             true,
+		"isolate":            true,
+	})
+
+	exported, err := exportConfig(d, []byte("syncer:\n  replicas: 2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config map[string]interface{}
+	if err := yaml.Unmarshal([]byte(exported), &config); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"name":               "test",
+		"namespace":          "team",
+		"distro":             "k8s",
+		"kubernetes_version": "1.25",
+		"chart":              "vcluster-k8s",
+		"chart_version":      "0.13.0",
+		"chart_repo":         "https://charts.example.com",
+		"expose":             true,
+		"expose_local":       false,
+		"isolate":            true,
+		"values": map[string]interface{}{
+			"syncer": map[string]interface{}{"replicas": float64(2)},
+		},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Fatalf("expected the exported config %v, got %v", expected, config)
+	}
+}
+
+func TestVClusterValuesDefaultStorageClass(t *testing.T) {
+	values := testValues(t, map[string]interface{}{
+		"name":                  "test",
+		"default_storage_class": "gp3",
+	})
+
+	expectValues(t, values, map[string]interface{}{
+		"sync.persistentvolumeclaims.defaultStorageClassName": "gp3",
+	})
+
+	expectValues(t, testValues(t, map[string]interface{}{"name": "test"}), map[string]interface{}{
+		"sync.persistentvolumeclaims.defaultStorageClassName": nil,
+	})
+}
+
+func TestVClusterValuesSyncExcludeSelector(t *testing.T) {
+	values := testValues(t, map[string]interface{}{"name": "test", "sync_exclude_selector": "team!=infra,tier in (batch)"})
+	expectValues(t, values, map[string]interface{}{
+		"sync.excludeLabelSelector": "team!=infra,tier in (batch)",
+	})
+
+	values = testValues(t, map[string]interface{}{"name": "test"})
+	expectValues(t, values, map[string]interface{}{
+		"sync": nil,
+	})
+
+	for _, selector := range []string{"=infra", "tier in batch", "team!!infra"} {
+		if diags := testValidate(map[string]interface{}{"name": "test", "sync_exclude_selector": selector}); !diags.HasError() {
+			t.Errorf("expected the selector %q to be rejected", selector)
+		}
+	}
+}
+
+func TestVClusterValuesServiceCIDRAndClusterDomain(t *testing.T) {
+	values := testValues(t, map[string]interface{}{
+		"name":           "test",
+		"service_cidr":   "10.96.0.0/12",
+		"cluster_domain": "cluster.example",
+	})
+
+	expectValues(t, values, map[string]interface{}{
+		"serviceCIDR":        "10.96.0.0/12",
+		"vcluster.extraArgs": []interface{}{"--cluster-domain=cluster.example"},
+		"syncer.extraArgs":   []interface{}{"--cluster-domain=cluster.example"},
+	})
+
+	expectValues(t, testValues(t, map[string]interface{}{"name": "test"}), map[string]interface{}{
+		"serviceCIDR": nil,
+		"vcluster":    nil,
+		"syncer":      nil,
+	})
+
+	for _, cidr := range []string{"10.96.0.0", "10.96.0.0/33", "not-a-cidr"} {
+		if diags := testValidate(map[string]interface{}{"name": "test", "service_cidr": cidr}); !diags.HasError() {
+			t.Errorf("expected the service cidr %q to be rejected", cidr)
+		}
+	}
+
+	for _, domain := range []string{"cluster local", "-cluster.local", "cluster..local"} {
+		if diags := testValidate(map[string]interface{}{"name": "test", "cluster_domain": domain}); !diags.HasError() {
+			t.Errorf("expected the cluster domain %q to be rejected", domain)
+		}
+	}
+}
+
+func TestVClusterValuesPriorityClassName(t *testing.T) {
+	values := testValues(t, map[string]interface{}{"name": "test", "priority_class_name": "system-cluster-critical"})
+	expectValues(t, values, map[string]interface{}{
+		"priorityClassName": "system-cluster-critical",
+	})
+
+	expectValues(t, testValues(t, map[string]interface{}{"name": "test"}), map[string]interface{}{
+		"priorityClassName": nil,
+	})
+
+	if diags := testValidate(map[string]interface{}{"name": "test", "priority_class_name": "System_Critical"}); !diags.HasError() {
+		t.Fatal("expected an invalid priority class name to be rejected")
+	}
+}
+
+func TestVClusterValuesProbes(t *testing.T) {
+	values := vclusterValues(testVClusterConfig(t, map[string]interface{}{
+		"name": "test",
+		"probes": []interface{}{map[string]interface{}{
+			"liveness": []interface{}{map[string]interface{}{
+				"initial_delay_seconds": 0,
+				"period_seconds":        20,
+				"failure_threshold":     10,
+			}},
+			"readiness": []interface{}{map[string]interface{}{
+				"period_seconds": 5,
+			}},
+		}},
+	}))
+
+	expectValues(t, values, map[string]interface{}{
+		"syncer.livenessProbe.initialDelaySeconds":  0,
+		"syncer.livenessProbe.periodSeconds":        20,
+		"syncer.livenessProbe.failureThreshold":     10,
+		"syncer.readinessProbe.periodSeconds":       5,
+		"syncer.readinessProbe.initialDelaySeconds": nil,
+		"syncer.readinessProbe.failureThreshold":    nil,
+	})
+
+	if diags := testValidate(map[string]interface{}{
+		"name": "test",
+		"probes": []interface{}{map[string]interface{}{
+			"liveness": []interface{}{map[string]interface{}{"period_seconds": 0}},
+		}},
+	}); !diags.HasError() {
+		t.Fatal("expected a zero period to be rejected")
+	}
+}
+
+func TestVClusterValuesPodAnnotations(t *testing.T) {
+	values := testValues(t, map[string]interface{}{
+		"name": "test",
+		"pod_annotations": map[string]interface{}{
+			"cluster-autoscaler.kubernetes.io/safe-to-evict": "false",
+		},
+	})
+
+	expectValues(t, values, map[string]interface{}{
+		"podAnnotations": map[string]interface{}{"cluster-autoscaler.kubernetes.io/safe-to-evict": "false"},
+	})
+
+	for _, key := range []string{"Example.COM/owner", "example.com/", "-owner"} {
+		diags := testValidate(map[string]interface{}{
+			"name":            "test",
+			"pod_annotations": map[string]interface{}{key: "team"},
+		})
+		if !diags.HasError() {
+			t.Errorf("expected the annotation key %q to be rejected", key)
+		}
+	}
+}
+
+func TestVClusterValuesMetrics(t *testing.T) {
+	cases := []struct {
+		metrics  map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{
+			metrics: map[string]interface{}{"service_monitor": true},
+			expected: map[string]interface{}{
+				"metrics.enabled":                   true,
+				"metrics.port":                      8443,
+				"monitoring.serviceMonitor.enabled": true,
+			},
+		},
+		{
+			metrics: map[string]interface{}{"port": 9090},
+			expected: map[string]interface{}{
+				"metrics.enabled":                   true,
+				"metrics.port":                      9090,
+				"monitoring.serviceMonitor.enabled": false,
+			},
+		},
+		{
+			// a ServiceMonitor without metrics would have nothing to scrape.
+			metrics: map[string]interface{}{"enabled": false, "service_monitor": true},
+			expected: map[string]interface{}{
+				"metrics.enabled":                   false,
+				"monitoring.serviceMonitor.enabled": false,
+			},
+		},
+	}
+
+	for _, c := range cases {
+		values := testValues(t, map[string]interface{}{
+			"name":    "test",
+			"metrics": []interface{}{c.metrics},
+		})
+		expectValues(t, values, c.expected)
+	}
+
+	expectValues(t, testValues(t, map[string]interface{}{"name": "test"}), map[string]interface{}{
+		"metrics":    nil,
+		"monitoring": nil,
+	})
+}
+
+func TestVClusterValuesCertRenewal(t *testing.T) {
+	values := testValues(t, map[string]interface{}{
+		"name":         "test",
+		"cert_renewal": []interface{}{map[string]interface{}{"before_expiry": "168h"}},
+	})
+	expectValues(t, values, map[string]interface{}{
+		"certs.renewal.enabled":      true,
+		"certs.renewal.beforeExpiry": "168h",
+	})
+
+	values = testValues(t, map[string]interface{}{
+		"name":         "test",
+		"cert_renewal": []interface{}{map[string]interface{}{"enabled": false}},
+	})
+	expectValues(t, values, map[string]interface{}{
+		"certs.renewal.enabled":      false,
+		"certs.renewal.beforeExpiry": "720h",
+	})
+
+	expectValues(t, testValues(t, map[string]interface{}{"name": "test"}), map[string]interface{}{
+		"certs": nil,
+	})
+
+	diags := testValidate(map[string]interface{}{
+		"name":         "test",
+		"cert_renewal": []interface{}{map[string]interface{}{"before_expiry": "30 days"}},
+	})
+	if !diags.HasError() {
+		t.Fatal("expected an invalid duration to be rejected")
+	}
+}
+
+func TestVClusterValuesDisableCoreDNS(t *testing.T) {
+	expectValues(t, testValues(t, map[string]interface{}{"name": "test", "disable_coredns": true}), map[string]interface{}{
+		"coredns.enabled": false,
+	})
+	expectValues(t, testValues(t, map[string]interface{}{"name": "test"}), map[string]interface{}{
+		"coredns": nil,
+	})
+
+	diags := testValidate(map[string]interface{}{"name": "test", "disable_coredns": true})
+	if len(diags) != 1 || diags[0].Severity != diag.Warning || diags[0].Summary != "CoreDNS is disabled" {
+		t.Fatalf("expected a warning that CoreDNS is disabled, got %v", diags)
+	}
+
+	if diags := testValidate(map[string]interface{}{"name": "test", "disable_coredns": false}); len(diags) != 0 {
+		t.Fatalf("expected no diagnostics, got %v", diags)
+	}
+}
+
+func TestVClusterValuesAdvertiseAddress(t *testing.T) {
+	values := testValues(t, map[string]interface{}{"name": "test", "advertise_address": "vcluster.example.com"})
+	expectValues(t, values, map[string]interface{}{
+		"vcluster.extraArgs": []interface{}{"--advertise-address=vcluster.example.com"},
+		"syncer.extraArgs":   []interface{}{"--tls-san=vcluster.example.com"},
+	})
+
+	if diags := testValidate(map[string]interface{}{"name": "test", "advertise_address": "https://vcluster.example.com"}); !diags.HasError() {
+		t.Fatal("expected an address with a scheme to be rejected")
+	}
+}
+
+func TestVClusterValuesRBAC(t *testing.T) {
+	cases := []struct {
+		scope    string
+		expected map[string]interface{}
+	}{
+		{scope: "cluster", expected: map[string]interface{}{"rbac.clusterRole.create": true, "rbac.role.create": true}},
+		{scope: "namespace", expected: map[string]interface{}{"rbac.clusterRole.create": false, "rbac.role.create": true}},
+	}
+
+	for _, c := range cases {
+		values := testValues(t, map[string]interface{}{
+			"name": "test",
+			"rbac": []interface{}{map[string]interface{}{"scope": c.scope}},
+		})
+		expectValues(t, values, c.expected)
+	}
+
+	expectValues(t, testValues(t, map[string]interface{}{"name": "test"}), map[string]interface{}{
+		"rbac": nil,
+	})
+
+	diags := testValidate(map[string]interface{}{
+		"name": "test",
+		"rbac": []interface{}{map[string]interface{}{"scope": "global"}},
+	})
+	if !diags.HasError() {
+		t.Fatal("expected an unknown scope to be rejected")
+	}
+}
+
+func TestVClusterValuesInitContainers(t *testing.T) {
+	values := testValues(t, map[string]interface{}{
+		"name": "test",
+		"init_containers": []interface{}{
+			map[string]interface{}{
+				"name":    "wait-for-storage",
+				"image":   "busybox:1.36",
+				"command": []interface{}{"sh", "-c"},
+				"args":    []interface{}{"until [ -d /data ]; do sleep 1; done"},
+				"env":     map[string]interface{}{"TIMEOUT": "60", "DEBUG": "1"},
+			},
+			map[string]interface{}{
+				"name":  "noop",
+				"image": "busybox:1.36",
+			},
+		},
+	})
+
+	expectValues(t, values, map[string]interface{}{
+		"initContainers": []interface{}{
+			map[string]interface{}{
+				"name":    "wait-for-storage",
+				"image":   "busybox:1.36",
+				"command": []interface{}{"sh", "-c"},
+				"args":    []interface{}{"until [ -d /data ]; do sleep 1; done"},
+				"env": []interface{}{
+					map[string]interface{}{"name": "DEBUG", "value": "1"},
+					map[string]interface{}{"name": "TIMEOUT", "value": "60"},
+				},
+			},
+			map[string]interface{}{
+				"name":  "noop",
+				"image": "busybox:1.36",
+			},
+		},
+	})
+
+	expectValues(t, testValues(t, map[string]interface{}{"name": "test"}), map[string]interface{}{
+		"initContainers": nil,
+	})
+}
+
+func TestVClusterValuesAudit(t *testing.T) {
+	policy := `apiVersion: audit.k8s.io/v1
+kind: Policy
+rules:
+- level: Metadata
+`
+
+	values := testValues(t, map[string]interface{}{
+		"name":  "test",
+		"audit": []interface{}{map[string]interface{}{"policy": policy, "log_path": "/var/log/audit.log"}},
+	})
+	expectValues(t, values, map[string]interface{}{
+		"audit.enabled": true,
+		"audit.policy": map[string]interface{}{
+			"apiVersion": "audit.k8s.io/v1",
+			"kind":       "Policy",
+			"rules":      []interface{}{map[string]interface{}{"level": "Metadata"}},
+		},
+		"vcluster.extraArgs": []interface{}{"--audit-log-path=/var/log/audit.log"},
+	})
+
+	values = testValues(t, map[string]interface{}{
+		"name":  "test",
+		"audit": []interface{}{map[string]interface{}{"policy": policy, "enabled": false}},
+	})
+	expectValues(t, values, map[string]interface{}{
+		"audit":              nil,
+		"vcluster.extraArgs": nil,
+	})
+
+	cases := []struct {
+		policy string
+		valid  bool
+	}{
+		{policy: policy, valid: true},
+		{policy: "apiVersion: audit.k8s.io/v1\nkind: Policy\nrules: []\n"},
+		{policy: "apiVersion: v1\nkind: ConfigMap\nrules:\n- level: Metadata\n"},
+		{policy: "apiVersion: audit.k8s.io/v1\nkind: [Policy\n"},
+	}
+
+	for _, c := range cases {
+		diags := validateAuditPolicy(c.policy, cty.GetAttrPath("policy"))
+		if diags.HasError() == c.valid {
+			t.Errorf("%q: expected valid to be %t, got %v", c.policy, c.valid, diags)
+		}
+	}
+}
+
+func TestVClusterValuesSecretSync(t *testing.T) {
+	cases := []struct {
+		mode     string
+		toHost   bool
+		fromHost bool
+	}{
+		{mode: "off"},
+		{mode: "to-host", toHost: true},
+		{mode: "from-host", fromHost: true},
+	}
+
+	for _, c := range cases {
+		values := testValues(t, map[string]interface{}{
+			"name":        "test",
+			"secret_sync": c.mode,
+			// secret_sync takes precedence over the secrets synced from the host.
+			"from_host_sync": []interface{}{map[string]interface{}{"kinds": []interface{}{"secrets"}}},
+		})
+		expectValues(t, values, map[string]interface{}{
+			"sync.secrets.enabled":          c.toHost,
+			"sync.fromHost.secrets.enabled": c.fromHost,
+		})
+	}
+
+	expectValues(t, testValues(t, map[string]interface{}{"name": "test"}), map[string]interface{}{
+		"sync.secrets":          nil,
+		"sync.fromHost.secrets": nil,
+	})
+
+	if diags := testValidate(map[string]interface{}{"name": "test", "secret_sync": "both"}); !diags.HasError() {
+		t.Fatal("expected an unknown mode to be rejected")
+	}
+}
+
+func TestVClusterValuesExtraVolumes(t *testing.T) {
+	values := testValues(t, map[string]interface{}{
+		"name": "test",
+		"extra_volumes": []interface{}{
+			map[string]interface{}{"name": "config", "config_map": "vcluster-config"},
+			map[string]interface{}{"name": "certs", "secret": "vcluster-certs"},
+			map[string]interface{}{"name": "scratch", "empty_dir": true},
+		},
+		"extra_volume_mounts": []interface{}{
+			map[string]interface{}{"name": "config", "mount_path": "/etc/vcluster", "sub_path": "config.yaml"},
+			map[string]interface{}{"name": "certs", "mount_path": "/etc/certs", "read_only": true},
+		},
+	})
+
+	expectValues(t, values, map[string]interface{}{
+		"volumes": []interface{}{
+			map[string]interface{}{"name": "config", "configMap": map[string]interface{}{"name": "vcluster-config"}},
+			map[string]interface{}{"name": "certs", "secret": map[string]interface{}{"secretName": "vcluster-certs"}},
+			map[string]interface{}{"name": "scratch", "emptyDir": map[string]interface{}{}},
+		},
+		"vcluster.volumeMounts": []interface{}{
+			map[string]interface{}{"name": "config", "mountPath": "/etc/vcluster", "readOnly": false, "subPath": "config.yaml"},
+			map[string]interface{}{"name": "certs", "mountPath": "/etc/certs", "readOnly": true},
+		},
+	})
+
+	expectValues(t, testValues(t, map[string]interface{}{"name": "test"}), map[string]interface{}{
+		"volumes":               nil,
+		"vcluster.volumeMounts": nil,
+	})
+}
+
+func TestVClusterValuesHostRewrite(t *testing.T) {
+	values := testValues(t, map[string]interface{}{
+		"name": "test",
+		"host_rewrite": []interface{}{map[string]interface{}{
+			"rule": []interface{}{
+				map[string]interface{}{"virtual": "default/database", "host": "data/postgres"},
+				map[string]interface{}{"virtual": "default/cache", "host": "data/redis"},
+			},
+		}},
+	})
+
+	expectValues(t, values, map[string]interface{}{
+		"mapServices.fromVirtual": []interface{}{
+			map[string]interface{}{"from": "default/database", "to": "data/postgres"},
+			map[string]interface{}{"from": "default/cache", "to": "data/redis"},
+		},
+	})
+
+	expectValues(t, testValues(t, map[string]interface{}{"name": "test"}), map[string]interface{}{
+		"mapServices": nil,
+	})
+
+	diags := testValidate(map[string]interface{}{
+		"name": "test",
+		"host_rewrite": []interface{}{map[string]interface{}{
+			"rule": []interface{}{map[string]interface{}{"virtual": "database", "host": "data/postgres"}},
+		}},
+	})
+	if !diags.HasError() {
+		t.Fatal("expected a service without a namespace to be rejected")
+	}
+}
+
+func TestVClusterValuesRegistry(t *testing.T) {
+	values := testValues(t, map[string]interface{}{
+		"name":     "test",
+		"edition":  "pro",
+		"registry": []interface{}{map[string]interface{}{"storage_size": "20Gi"}},
+	})
+	expectValues(t, values, map[string]interface{}{
+		"registry.enabled":             true,
+		"registry.persistence.enabled": true,
+		"registry.persistence.size":    "20Gi",
+	})
+
+	values = testValues(t, map[string]interface{}{
+		"name":     "test",
+		"edition":  "pro",
+		"registry": []interface{}{map[string]interface{}{"persistence": false}},
+	})
+	expectValues(t, values, map[string]interface{}{
+		"registry.enabled":             true,
+		"registry.persistence.enabled": false,
+		"registry.persistence.size":    nil,
+	})
+
+	expectValues(t, testValues(t, map[string]interface{}{"name": "test"}), map[string]interface{}{
+		"registry": nil,
+	})
+
+	if diags := testValidate(map[string]interface{}{
+		"name":     "test",
+		"edition":  "pro",
+		"registry": []interface{}{map[string]interface{}{"storage_size": "lots"}},
+	}); !diags.HasError() {
+		t.Fatal("expected an invalid storage size to be rejected")
+	}
+}
+
+func TestComposeValuesMergeOrder(t *testing.T) {
+	meta, _ := testMeta(t, map[string]interface{}{
+		"base_values": []interface{}{
+			"priorityClassName: base\nsyncer:\n  replicas: 1\n  image: base\n",
+			"syncer:\n  image: second-base\nstorage:\n  size: 5Gi\n",
+		},
+	})
+
+	d := testVCluster(t, map[string]interface{}{
+		"name":                "test",
+		"priority_class_name": "resource",
+		"extra_values": []interface{}{
+			"syncer:\n  replicas: 2\nstorage:\n  size: 10Gi\n",
+			"storage:\n  size: 20Gi\n",
+		},
+	})
+
+	composed, err := composeValues(d, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(composed, &values); err != nil {
+		t.Fatal(err)
+	}
+
+	expectValues(t, values, map[string]interface{}{
+		// the attributes override the base values.
+		"priorityClassName": "resource",
+		// the base values override each other in order, and nested values are merged rather than replaced.
+		"syncer.image": "second-base",
+		// the extra values override the base values and each other in order.
+		"syncer.replicas": float64(2),
+		"storage.size":    "20Gi",
+	})
+
+	d = testVCluster(t, map[string]interface{}{
+		"name":         "test",
+		"extra_values": []interface{}{"priorityClassName: extra\n"},
+	})
+	composed, err = composeValues(d, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values = nil
+	if err := yaml.Unmarshal(composed, &values); err != nil {
+		t.Fatal(err)
+	}
+	expectValues(t, values, map[string]interface{}{"priorityClassName": "extra"})
+
+	d = testVCluster(t, map[string]interface{}{
+		"name":         "test",
+		"extra_values": []interface{}{"syncer: [\n"},
+	})
+	if _, err := composeValues(d, meta); err == nil || !strings.HasPrefix(err.Error(), "extra_values.0:") {
+		t.Fatalf("expected the invalid extra values to be reported, got %v", err)
+	}
+}
+
+func TestVClusterValuesTerminationGracePeriod(t *testing.T) {
+	cases := []struct {
+		raw      map[string]interface{}
+		expected interface{}
+	}{
+		{raw: map[string]interface{}{"name": "test", "termination_grace_period_seconds": 120}, expected: 120},
+		// 0 is a valid grace period, which is only rendered when it is configured.
+		{raw: map[string]interface{}{"name": "test", "termination_grace_period_seconds": 0}, expected: 0},
+		{raw: map[string]interface{}{"name": "test"}, expected: nil},
+	}
+
+	for _, c := range cases {
+		values := vclusterValues(testVClusterConfig(t, c.raw))
+		expectValues(t, values, map[string]interface{}{
+			"terminationGracePeriodSeconds": c.expected,
+		})
+	}
+
+	if diags := testValidate(map[string]interface{}{"name": "test", "termination_grace_period_seconds": -1}); !diags.HasError() {
+		t.Fatal("expected a negative grace period to be rejected")
+	}
+}
+
+func TestVClusterValuesEgressProxy(t *testing.T) {
+	values := testValues(t, map[string]interface{}{
+		"name": "test",
+		"egress_proxy": []interface{}{map[string]interface{}{
+			"http_proxy":  "http://proxy.example.com:3128",
+			"https_proxy": "http://proxy.example.com:3129",
+			"no_proxy":    []interface{}{"10.96.0.0/12", ".svc", "localhost"},
+		}},
+	})
+
+	env := []interface{}{
+		map[string]interface{}{"name": "HTTPS_PROXY", "value": "http://proxy.example.com:3129"},
+		map[string]interface{}{"name": "HTTP_PROXY", "value": "http://proxy.example.com:3128"},
+		map[string]interface{}{"name": "NO_PROXY", "value": "10.96.0.0/12,.svc,localhost"},
+	}
+	expectValues(t, values, map[string]interface{}{
+		"vcluster.env": env,
+		"syncer.env":   env,
+	})
+
+	// unset proxies are not rendered.
+	values = testValues(t, map[string]interface{}{
+		"name":         "test",
+		"egress_proxy": []interface{}{map[string]interface{}{"https_proxy": "http://proxy.example.com:3129"}},
+	})
+	env = []interface{}{
+		map[string]interface{}{"name": "HTTPS_PROXY", "value": "http://proxy.example.com:3129"},
+	}
+	expectValues(t, values, map[string]interface{}{
+		"vcluster.env": env,
+		"syncer.env":   env,
+	})
+
+	diags := testValidate(map[string]interface{}{
+		"name":         "test",
+		"egress_proxy": []interface{}{map[string]interface{}{"http_proxy": "proxy.example.com:3128"}},
+	})
+	if !diags.HasError() {
+		t.Fatal("expected a proxy without a scheme to be rejected")
+	}
+}
+
+func TestVClusterValuesEnforceNodeSelector(t *testing.T) {
+	values := testValues(t, map[string]interface{}{
+		"name":                  "test",
+		"enforce_node_selector": map[string]interface{}{"pool": "tenants", "kubernetes.io/os": "linux"},
+		"syncer_log_level":      2,
+	})
+	expectValues(t, values, map[string]interface{}{
+		"syncer.extraArgs": []interface{}{
+			"--node-selector=kubernetes.io/os=linux,pool=tenants",
+			"--enforce-node-selector",
+			"--v=2",
+		},
+	})
+
+	expectValues(t, testValues(t, map[string]interface{}{"name": "test"}), map[string]interface{}{
+		"syncer.extraArgs": nil,
+	})
+
+	diags := testValidate(map[string]interface{}{
+		"name":                  "test",
+		"enforce_node_selector": map[string]interface{}{"pool": "not a label value"},
+	})
+	if !diags.HasError() {
+		t.Fatal("expected an invalid label value to be rejected")
+	}
+}
+
+func TestVClusterValuesSchedulerName(t *testing.T) {
+	values := testValues(t, map[string]interface{}{"name": "test", "scheduler_name": "tenant-scheduler"})
+	expectValues(t, values, map[string]interface{}{
+		"sync.pods.schedulerName": "tenant-scheduler",
+	})
+
+	expectValues(t, testValues(t, map[string]interface{}{"name": "test"}), map[string]interface{}{
+		"sync.pods": nil,
+	})
+
+	if diags := testValidate(map[string]interface{}{"name": "test", "scheduler_name": "Tenant Scheduler"}); !diags.HasError() {
+		t.Fatal("expected an invalid scheduler name to be rejected")
+	}
+}
@@ -0,0 +1,42 @@
+package vcluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// versionCache caches the versions of the vcluster cli binaries by their path.
+type versionCache struct {
+	sync.Mutex
+	versions map[string]string
+}
+
+// vclusterCLIVersion returns the version of the installed vcluster cli, as reported by `vcluster --version`.
+func vclusterCLIVersion(ctx context.Context, meta *Meta) (string, diag.Diagnostics) {
+	meta.versions.Lock()
+	defer meta.versions.Unlock()
+
+	if cached, ok := meta.versions.versions[meta.binaryPath]; ok {
+		return cached, nil
+	}
+
+	output, diags := runCommand(ctx, meta, vclusterBinary(meta), []string{"--version"})
+	if diags.HasError() {
+		return "", diags
+	}
+
+	// the cli prints its version as "vcluster version 0.13.0"
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return "", diag.FromErr(fmt.Errorf("unable to parse vcluster version from %q", string(output)))
+	}
+
+	cliVersion := strings.TrimPrefix(fields[len(fields)-1], "v")
+	meta.versions.versions[meta.binaryPath] = cliVersion
+
+	return cliVersion, nil
+}